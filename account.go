@@ -0,0 +1,287 @@
+package wabaapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ansel1/merry"
+)
+
+// Wallet is the balance of the Gupshup account. Messages fail when the
+// balance goes below the overdraft limit
+type Wallet struct {
+	Currency       string  `json:"currency"`
+	CurrentBalance float64 `json:"currentBalance"`
+	OverdraftLimit float64 `json:"overDraftLimit"`
+}
+
+// WalletBalance returns the balance of the account
+func (c *Client) WalletBalance(ctx context.Context) (Wallet, error) {
+	var resp struct {
+		Status string `json:"status"`
+		Wallet Wallet `json:"walletResponse"`
+	}
+	if _, err := c.doURL(ctx, http.MethodGet, c.v2URL()+"/wallet/balance", nil, "", &resp); err != nil {
+		return Wallet{}, err
+	}
+	return resp.Wallet, nil
+}
+
+// AppHealth is the health of an app, unhealthy apps cannot send messages
+type AppHealth struct {
+	Healthy bool
+}
+
+func (h *AppHealth) UnmarshalJSON(data []byte) error {
+	var tmp struct {
+		Healthy interface{} `json:"healthy"`
+	}
+	if err := json.Unmarshal(data, &tmp); err != nil {
+		return err
+	}
+	switch v := tmp.Healthy.(type) {
+	case bool:
+		h.Healthy = v
+	case string:
+		h.Healthy, _ = strconv.ParseBool(v)
+	}
+	return nil
+}
+
+// AppHealth returns the health of app
+func (c *Client) AppHealth(ctx context.Context, app string) (AppHealth, error) {
+	var health AppHealth
+	_, err := c.do(ctx, http.MethodGet, "/app/"+url.PathEscape(app)+"/health", nil, "", &health)
+	return health, err
+}
+
+// Quality ratings of a phone number
+const (
+	QualityGreen  = "GREEN"
+	QualityYellow = "YELLOW"
+	QualityRed    = "RED"
+)
+
+// QualityRating is the quality rating and messaging tier of the app's number
+type QualityRating struct {
+	// Rating is QualityGreen, QualityYellow or QualityRed
+	Rating string `json:"qualityRating"`
+	// Tier is the messaging limit of business initiated conversations per
+	// day, like TIER_1K, TIER_10K, TIER_100K or TIER_UNLIMITED
+	Tier string `json:"currentLimit"`
+	// PreviousTier is the tier before the last Event
+	PreviousTier string `json:"oldLimit,omitempty"`
+	// Event is the last change, like UPGRADE, DOWNGRADE or FLAGGED
+	Event string `json:"event,omitempty"`
+}
+
+var (
+	ratingRanks = map[string]int{QualityRed: 1, QualityYellow: 2, QualityGreen: 3}
+	tierRanks   = map[string]int{"TIER_50": 1, "TIER_250": 2, "TIER_1K": 3, "TIER_10K": 4, "TIER_100K": 5, "TIER_UNLIMITED": 6}
+)
+
+// Degraded reports whether the rating or the tier is lower than prev's.
+// Unknown values are not compared
+func (q QualityRating) Degraded(prev QualityRating) bool {
+	lower := func(ranks map[string]int, cur, prev string) bool {
+		c, ok1 := ranks[cur]
+		p, ok2 := ranks[prev]
+		return ok1 && ok2 && c < p
+	}
+	return lower(ratingRanks, q.Rating, prev.Rating) || lower(tierRanks, q.Tier, prev.Tier)
+}
+
+// QualityRating returns the quality rating and messaging tier of app
+func (c *Client) QualityRating(ctx context.Context, app string) (QualityRating, error) {
+	var rating QualityRating
+	_, err := c.do(ctx, http.MethodGet, "/app/"+url.PathEscape(app)+"/ratings", nil, "", &rating)
+	return rating, err
+}
+
+// AccountMonitor polls the wallet, health and quality rating of an app and
+// calls the callbacks on changes. Run it in a goroutine and pass the
+// account-events to HandleEvent to get quality updates as they happen.
+// Callbacks are called from the polling goroutine or from HandleEvent
+type AccountMonitor struct {
+	Client   *Client
+	App      string
+	Interval time.Duration
+	// LowBalance is the balance threshold of OnLowBalance
+	LowBalance float64
+
+	// OnLowBalance is called when the balance drops below LowBalance, and
+	// again only after it recovers
+	OnLowBalance func(w Wallet)
+	// OnUnhealthy is called when the app becomes unhealthy
+	OnUnhealthy func(h AppHealth)
+	// OnQualityDegraded is called when the rating or tier gets lower
+	OnQualityDegraded func(prev QualityRating, cur QualityRating)
+	// OnError is called with the polling errors
+	OnError func(err error)
+
+	mu        sync.Mutex
+	low       bool
+	unhealthy bool
+	quality   *QualityRating
+}
+
+// DefaultMonitorInterval is the AccountMonitor polling interval when not set
+const DefaultMonitorInterval = 5 * time.Minute
+
+// Run checks the account every Interval until ctx is done
+func (m *AccountMonitor) Run(ctx context.Context) error {
+	interval := m.Interval
+	if interval <= 0 {
+		interval = DefaultMonitorInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := m.Check(ctx); err != nil && m.OnError != nil {
+			m.OnError(err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Check polls the account once and calls the callbacks. The wallet, health
+// and quality rating are all polled when one of them fails, the errors are
+// returned together
+func (m *AccountMonitor) Check(ctx context.Context) error {
+	var errs []error
+	if wallet, err := m.Client.WalletBalance(ctx); err != nil {
+		errs = append(errs, merry.Prepend(err, "wallet balance"))
+	} else {
+		m.updateWallet(wallet)
+	}
+
+	if health, err := m.Client.AppHealth(ctx, m.App); err != nil {
+		errs = append(errs, merry.Prepend(err, "app health"))
+	} else {
+		m.updateHealth(health)
+	}
+
+	if rating, err := m.Client.QualityRating(ctx, m.App); err != nil {
+		errs = append(errs, merry.Prepend(err, "quality rating"))
+	} else {
+		m.updateQuality(false, func(*QualityRating) QualityRating { return rating })
+	}
+	return joinErrors(errs)
+}
+
+// joinErrors returns the first of errs with the messages of the rest appended
+func joinErrors(errs []error) error {
+	if len(errs) == 0 {
+		return nil
+	}
+	err := errs[0]
+	for _, other := range errs[1:] {
+		err = merry.Append(err, other.Error())
+	}
+	return err
+}
+
+// HandleEvent updates the quality rating with quality and tier account-events
+// of the monitored app. Other callbacks are ignored. DOWNGRADE and FLAGGED
+// events call OnQualityDegraded even before the first Check, with an empty
+// previous rating
+func (m *AccountMonitor) HandleEvent(msg *InboundMessage) error {
+	ev, ok := msg.AsAccountEvent()
+	if !ok || (m.App != "" && msg.App != m.App) {
+		return nil
+	}
+
+	if ev.Type != "quality-update" && ev.Type != "tier-update" {
+		return nil
+	}
+	str := func(key string) string {
+		s, _ := ev.Payload[key].(string)
+		return s
+	}
+
+	m.updateQuality(true, func(prev *QualityRating) QualityRating {
+		rating := QualityRating{}
+		if prev != nil {
+			rating = *prev
+		}
+		if v := str("qualityRating"); v != "" {
+			rating.Rating = v
+		}
+		if v := str("currentLimit"); v != "" {
+			rating.PreviousTier, rating.Tier = rating.Tier, v
+		}
+		if v := str("oldLimit"); v != "" {
+			rating.PreviousTier = v
+		}
+		rating.Event = str("event")
+		return rating
+	})
+	return nil
+}
+
+// Quality returns the last known quality rating
+func (m *AccountMonitor) Quality() (QualityRating, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.quality == nil {
+		return QualityRating{}, false
+	}
+	return *m.quality, true
+}
+
+func (m *AccountMonitor) updateWallet(w Wallet) {
+	m.mu.Lock()
+	wasLow := m.low
+	m.low = w.CurrentBalance < m.LowBalance
+	notify := m.low && !wasLow
+	m.mu.Unlock()
+
+	if notify && m.OnLowBalance != nil {
+		m.OnLowBalance(w)
+	}
+}
+
+func (m *AccountMonitor) updateHealth(h AppHealth) {
+	m.mu.Lock()
+	was := m.unhealthy
+	m.unhealthy = !h.Healthy
+	notify := m.unhealthy && !was
+	m.mu.Unlock()
+
+	if notify && m.OnUnhealthy != nil {
+		m.OnUnhealthy(h)
+	}
+}
+
+// updateQuality replaces the quality rating with update of the previous one,
+// nil when unknown, and calls OnQualityDegraded when it gets worse. Without a
+// previous rating only the DOWNGRADE and FLAGGED events of account-events do
+func (m *AccountMonitor) updateQuality(event bool, update func(prev *QualityRating) QualityRating) {
+	m.mu.Lock()
+	prev := m.quality
+	q := update(prev)
+	m.quality = &q
+	m.mu.Unlock()
+
+	flagged := q.Event == "DOWNGRADE" || q.Event == "FLAGGED"
+	var degraded bool
+	if prev == nil {
+		degraded = event && flagged
+		prev = &QualityRating{}
+	} else {
+		degraded = q.Degraded(*prev) || (flagged && q.Event != prev.Event)
+	}
+	if degraded && m.OnQualityDegraded != nil {
+		m.OnQualityDegraded(*prev, q)
+	}
+}
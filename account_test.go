@@ -0,0 +1,63 @@
+package wabaapi
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQualityRatingDegraded(t *testing.T) {
+	green := QualityRating{Rating: QualityGreen, Tier: "TIER_10K"}
+	assert.False(t, green.Degraded(green))
+	assert.True(t, QualityRating{Rating: QualityYellow, Tier: "TIER_10K"}.Degraded(green))
+	assert.True(t, QualityRating{Rating: QualityGreen, Tier: "TIER_1K"}.Degraded(green))
+	assert.False(t, QualityRating{Rating: QualityGreen, Tier: "TIER_100K"}.Degraded(green))
+	assert.False(t, QualityRating{Rating: "UNKNOWN", Tier: "TIER_10K"}.Degraded(green))
+}
+
+func TestAccountMonitorCheckErrors(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/sm/api/v2/wallet/balance", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"status":"error","message":"wallet down"}`, http.StatusInternalServerError)
+	})
+	mux.HandleFunc("/sm/api/v1/app/demo/health", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"status":"success","healthy":"false"}`))
+	})
+	mux.HandleFunc("/sm/api/v1/app/demo/ratings", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"status":"error","message":"ratings down"}`, http.StatusServiceUnavailable)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	unhealthy := 0
+	mon := &AccountMonitor{
+		Client:      &Client{APIKey: "key", BaseURL: srv.URL + "/sm/api/v1"},
+		App:         "demo",
+		OnUnhealthy: func(AppHealth) { unhealthy++ },
+	}
+	err := mon.Check(context.Background())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "wallet balance")
+	assert.Contains(t, err.Error(), "quality rating")
+	assert.Equal(t, 1, unhealthy, "health is checked when the wallet fails")
+}
+
+func TestAccountMonitorEventBeforeCheck(t *testing.T) {
+	var degraded []QualityRating
+	mon := &AccountMonitor{App: "demo", OnQualityDegraded: func(prev, cur QualityRating) { degraded = append(degraded, cur) }}
+
+	event := func(payload map[string]interface{}) *InboundMessage {
+		return &InboundMessage{App: "demo", Type: "account-event", Payload: AccountEventPayload{Type: "tier-update", Payload: payload}}
+	}
+	require.NoError(t, mon.HandleEvent(event(map[string]interface{}{"currentLimit": "TIER_10K", "event": "UPGRADE"})))
+	assert.Empty(t, degraded)
+
+	mon = &AccountMonitor{App: "demo", OnQualityDegraded: func(prev, cur QualityRating) { degraded = append(degraded, cur) }}
+	require.NoError(t, mon.HandleEvent(event(map[string]interface{}{"currentLimit": "TIER_250", "event": "DOWNGRADE"})))
+	require.Len(t, degraded, 1)
+	assert.Equal(t, "TIER_250", degraded[0].Tier)
+}
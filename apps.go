@@ -0,0 +1,135 @@
+package wabaapi
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"net/url"
+	"sync"
+
+	"github.com/ansel1/merry"
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+)
+
+// ErrUnknownApp is returned by AppRouter for callbacks of apps not registered
+var ErrUnknownApp = merry.New("unknown app").WithHTTPCode(http.StatusForbidden)
+
+// App is a Gupshup app, a WhatsApp number with its own API key and handler
+type App struct {
+	// Name is the Gupshup app name, the App of its callbacks
+	Name          string
+	APIKey        string
+	Source        string
+	SourceName    string
+	DefaultRegion string
+	// Handle receives the callbacks of the app
+	Handle func(app *App, msg *InboundMessage) error
+	// Client sends the app messages, AppRouter.Register creates it with APIKey when nil
+	Client *Client
+}
+
+func (a *App) Validate() error {
+	return validation.ValidateStruct(a,
+		validation.Field(&a.Name, validation.Required),
+		validation.Field(&a.APIKey, validation.Required),
+		validation.Field(&a.Source, validation.Required),
+		validation.Field(&a.SourceName, validation.Required),
+	)
+}
+
+// Outbound returns an OutboundMessage to destination with the app defaults
+func (a *App) Outbound(destination string) *OutboundMessage {
+	return &OutboundMessage{
+		Channel:       "whatsapp",
+		Destination:   destination,
+		DefaultRegion: a.DefaultRegion,
+		Source:        a.Source,
+		SourceName:    a.SourceName,
+	}
+}
+
+// Reply returns an OutboundMessage to the user of msg: the sender of a message,
+// the destination of a message-event or the phone of a user-event. Gupshup
+// sends these numbers with country code so DefaultRegion is not applied
+func (a *App) Reply(msg *InboundMessage) (*OutboundMessage, error) {
+	var phone string
+	switch p := msg.Payload.(type) {
+	case InboundMessagePayload:
+		phone = p.Source
+	case MessageEventPayload:
+		phone = p.Destination
+	case UserEventPayload:
+		phone = p.Phone
+	default:
+		return nil, merry.Errorf("%s callbacks have no user to reply to", msg.Type)
+	}
+	om := a.Outbound(phone)
+	om.DefaultRegion = ""
+	return om, nil
+}
+
+// Send sends values with the app client
+func (a *App) Send(ctx context.Context, values url.Values) (string, error) {
+	return a.Client.Send(ctx, values)
+}
+
+// AppRouter dispatches the callbacks of several apps sharing a webhook to the
+// app with the callback's App. Use its Handle as the WebhookHandler Handle.
+// Callbacks of unknown apps are logged to ErrorLog and rejected with ErrUnknownApp
+type AppRouter struct {
+	// ErrorLog defaults to the standard logger
+	ErrorLog *log.Logger
+
+	mu   sync.RWMutex
+	apps map[string]*App
+}
+
+// Register adds app to the router
+func (r *AppRouter) Register(app *App) error {
+	if err := app.Validate(); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.apps[app.Name]; ok {
+		return merry.Errorf("app %s already registered", app.Name)
+	}
+	if app.Client == nil {
+		app.Client = &Client{APIKey: app.APIKey}
+	}
+	if r.apps == nil {
+		r.apps = map[string]*App{}
+	}
+	r.apps[app.Name] = app
+	return nil
+}
+
+// App returns the registered app with name
+func (r *AppRouter) App(name string) (*App, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	app, ok := r.apps[name]
+	return app, ok
+}
+
+// Handle passes msg to the Handle of its app
+func (r *AppRouter) Handle(msg *InboundMessage) error {
+	app, ok := r.App(msg.App)
+	if !ok {
+		r.logf("wabaapi: rejected %s callback of unknown app %q", msg.Type, msg.App)
+		return ErrUnknownApp.Append(msg.App)
+	}
+	if app.Handle == nil {
+		return nil
+	}
+	return app.Handle(app, msg)
+}
+
+func (r *AppRouter) logf(format string, args ...interface{}) {
+	if r.ErrorLog != nil {
+		r.ErrorLog.Printf(format, args...)
+		return
+	}
+	log.Printf(format, args...)
+}
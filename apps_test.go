@@ -0,0 +1,78 @@
+package wabaapi
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ansel1/merry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAppRouter(t *testing.T) {
+	var logs bytes.Buffer
+	router := &AppRouter{ErrorLog: log.New(&logs, "", 0)}
+
+	var handled []string
+	var replies []*OutboundMessage
+	handle := func(app *App, msg *InboundMessage) error {
+		handled = append(handled, app.Name)
+		om, err := app.Reply(msg)
+		if err != nil {
+			return err
+		}
+		replies = append(replies, om)
+		return nil
+	}
+	require.NoError(t, router.Register(&App{Name: "shop", APIKey: "k1", Source: "34900000001", SourceName: "Shop", Handle: handle}))
+	require.NoError(t, router.Register(&App{Name: "support", APIKey: "k2", Source: "34900000002", SourceName: "Support", Handle: handle}))
+	assert.Error(t, router.Register(&App{Name: "shop", APIKey: "k3", Source: "34900000003", SourceName: "Shop"}))
+	assert.Error(t, router.Register(&App{Name: "nokey", Source: "34900000003", SourceName: "Shop"}))
+
+	app, ok := router.App("support")
+	require.True(t, ok)
+	assert.Equal(t, "k2", app.Client.APIKey)
+
+	wh := &WebhookHandler{Handle: router.Handle}
+	post := func(body string) int {
+		rec := httptest.NewRecorder()
+		wh.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body)))
+		return rec.Code
+	}
+
+	assert.Equal(t, http.StatusOK, post(`{"app":"support","timestamp":1580227766370,"type":"message","payload":{"id":"1","source":"34600000001","type":"text","payload":{"text":"hi"}}}`))
+	assert.Equal(t, http.StatusOK, post(`{"app":"shop","timestamp":1580227766370,"type":"user-event","payload":{"phone":"34600000002","type":"opted-in"}}`))
+	assert.Equal(t, http.StatusForbidden, post(`{"app":"other","timestamp":1580227766370,"type":"user-event","payload":{"phone":"34600000002","type":"opted-in"}}`))
+
+	assert.Equal(t, []string{"support", "shop"}, handled)
+	require.Len(t, replies, 2)
+	assert.Equal(t, OutboundMessage{Channel: "whatsapp", Destination: "34600000001", Source: "34900000002", SourceName: "Support"}, *replies[0])
+	assert.Equal(t, "34900000001", replies[1].Source)
+	assert.Equal(t, "34600000002", replies[1].Destination)
+	assert.Contains(t, logs.String(), `unknown app "other"`)
+
+	err := router.Handle(&InboundMessage{App: "other"})
+	assert.True(t, merry.Is(err, ErrUnknownApp))
+}
+
+func TestAppReplyForeignSender(t *testing.T) {
+	app := &App{Name: "shop", APIKey: "k1", Source: "34900000001", SourceName: "Shop", DefaultRegion: "ES"}
+	om, err := app.Reply(&InboundMessage{Type: "message", Payload: InboundMessagePayload{Source: "919876543210"}})
+	require.NoError(t, err)
+	values, err := om.Text("hi")
+	require.NoError(t, err)
+	assert.Equal(t, "919876543210", values.Get("destination"))
+	assert.Equal(t, "ES", app.Outbound("600000001").DefaultRegion)
+}
+
+func TestAppRouterRegisterDuplicate(t *testing.T) {
+	router := &AppRouter{}
+	require.NoError(t, router.Register(&App{Name: "shop", APIKey: "k1", Source: "34900000001", SourceName: "Shop"}))
+	dup := &App{Name: "shop", APIKey: "k2", Source: "34900000002", SourceName: "Shop"}
+	assert.Error(t, router.Register(dup))
+	assert.Nil(t, dup.Client)
+}
@@ -0,0 +1,134 @@
+package billing
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	wabaapi "github.com/panitaxx/gupshup-wabaapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const prices = `category,country,price
+marketing,ES,0.0615
+marketing,*,0.05
+utility,ES,0.02
+authentication,*,0.03
+`
+
+func event(t *testing.T, app string, ts int64, destination string, conversation string, category string, billable bool) *wabaapi.InboundMessage {
+	raw := fmt.Sprintf(`{"app":%q,"timestamp":%d,"type":"message-event","payload":{"id":"w1","gsId":"g1","type":"sent","destination":%q,
+		"payload":{"ts":1},"conversation":{"id":%q,"expiresAt":%d,"type":%q},
+		"pricing":{"policy":"CBP","category":%q,"billable":%t}}}`, app, ts, destination, conversation, ts/1000+24*3600, category, category, billable)
+	var msg wabaapi.InboundMessage
+	require.NoError(t, json.Unmarshal([]byte(raw), &msg))
+	return &msg
+}
+
+func TestLedger(t *testing.T) {
+	table, err := ReadPricesCSV(strings.NewReader(prices), "USD")
+	require.NoError(t, err)
+
+	day := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC).UnixNano() / int64(time.Millisecond)
+	l := &Ledger{Prices: table}
+	for _, msg := range []*wabaapi.InboundMessage{
+		event(t, "shop", day, "34600000001", "c1", "marketing", true),
+		event(t, "shop", day, "34600000001", "c1", "marketing", true), // delivered event of the same conversation
+		event(t, "shop", day, "34600000002", "c2", "marketing", true),
+		event(t, "shop", day, "447700900123", "c3", "marketing", true),
+		event(t, "shop", day, "34600000003", "c4", "service", false),
+		event(t, "shop", day, "34600000003", "c5", "service", true),
+		event(t, "support", day+int64(24*time.Hour/time.Millisecond), "34600000001", "c6", "utility", true),
+	} {
+		require.NoError(t, l.Handle(msg))
+	}
+
+	report := l.Report()
+	require.Len(t, report, 4)
+	assert.Equal(t, Entry{Key: Key{App: "shop", Category: "marketing", Country: "ES", Day: "2024-03-01"},
+		Conversations: 2, Billable: 2, Cost: 0.123, Currency: "USD"}, report[0])
+	assert.Equal(t, "GB", report[1].Country)
+	assert.Equal(t, 0.05, report[1].Cost)
+	assert.Equal(t, Entry{Key: Key{App: "shop", Category: "service", Country: "ES", Day: "2024-03-01"},
+		Conversations: 2, Billable: 1, Unpriced: 1, Currency: "USD"}, report[2])
+	assert.Equal(t, "2024-03-02", report[3].Day)
+
+	var csvOut bytes.Buffer
+	require.NoError(t, l.WriteCSV(&csvOut))
+	lines := strings.Split(strings.TrimSpace(csvOut.String()), "\n")
+	assert.Len(t, lines, 5)
+	assert.Equal(t, "2024-03-01,shop,marketing,ES,2,2,0,0.1230,USD", lines[1])
+
+	var jsonOut bytes.Buffer
+	require.NoError(t, l.WriteJSON(&jsonOut))
+	var decoded []Entry
+	require.NoError(t, json.Unmarshal(jsonOut.Bytes(), &decoded))
+	assert.Equal(t, report, decoded)
+}
+
+func TestLedgerConversationStart(t *testing.T) {
+	table, err := ReadPricesCSV(strings.NewReader(prices), "USD")
+	require.NoError(t, err)
+
+	l := &Ledger{Prices: table}
+	started := time.Date(2024, 3, 1, 23, 0, 0, 0, time.UTC)
+	l.Add("shop", started.Add(2*time.Hour), wabaapi.MessageEventPayload{
+		Destination:  "34600000001",
+		Conversation: &wabaapi.Conversation{ID: "c1", ExpiresAt: started.Add(24 * time.Hour), Type: "MARKETING"},
+		Pricing:      &wabaapi.Pricing{Policy: "CBP", Category: "MARKETING"},
+	})
+	l.Add("shop", started, wabaapi.MessageEventPayload{
+		Destination:  "34600000001",
+		Conversation: &wabaapi.Conversation{ID: "c2", Type: "Utility"},
+	})
+
+	report := l.Report()
+	require.Len(t, report, 2)
+	assert.Equal(t, Entry{Key: Key{App: "shop", Category: "marketing", Country: "ES", Day: "2024-03-01"},
+		Conversations: 1, Billable: 1, Cost: 0.0615, Currency: "USD"}, report[0])
+	assert.Equal(t, Entry{Key: Key{App: "shop", Category: "utility", Country: "ES", Day: "2024-03-01"},
+		Conversations: 1, Billable: 1, Cost: 0.02, Currency: "USD"}, report[1])
+}
+
+func TestDatedPrices(t *testing.T) {
+	old := &StaticPrices{CurrencyCode: "USD", Prices: map[string]map[string]float64{"marketing": {AnyCountry: 0.05}}}
+	current := &StaticPrices{CurrencyCode: "USD", Prices: map[string]map[string]float64{"marketing": {AnyCountry: 0.06}}}
+	dp := DatedPrices{
+		{From: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC), Table: old},
+		{From: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), Table: current},
+	}
+
+	_, ok := dp.Price("marketing", "ES", time.Date(2022, 6, 1, 0, 0, 0, 0, time.UTC))
+	assert.False(t, ok)
+	price, _ := dp.Price("marketing", "ES", time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC))
+	assert.Equal(t, 0.05, price)
+	price, _ = dp.Price("marketing", "ES", time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC))
+	assert.Equal(t, 0.06, price)
+	assert.NoError(t, dp.Validate())
+
+	eur := &StaticPrices{CurrencyCode: "EUR", Prices: current.Prices}
+	assert.Error(t, DatedPrices{dp[0], {From: dp[1].From, Table: eur}}.Validate())
+	assert.Error(t, DatedPrices{dp[1], dp[0]}.Validate())
+}
+
+func TestLedgerDropsExpiredConversations(t *testing.T) {
+	l := &Ledger{}
+	start := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	add := func(id string, at time.Time) {
+		l.Add("shop", at, wabaapi.MessageEventPayload{
+			Destination:  "34600000001",
+			Conversation: &wabaapi.Conversation{ID: id, ExpiresAt: at.Add(24 * time.Hour), Type: "service"},
+		})
+	}
+	add("c1", start)
+	add("c1", start.Add(time.Hour))
+	assert.Len(t, l.conversations, 1)
+
+	add("c2", start.Add(25*time.Hour))
+	assert.Len(t, l.conversations, 1, "c1 expired")
+	assert.Equal(t, 2, l.Report()[0].Conversations+l.Report()[1].Conversations)
+}
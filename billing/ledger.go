@@ -0,0 +1,209 @@
+package billing
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ansel1/merry"
+	wabaapi "github.com/panitaxx/gupshup-wabaapi"
+	"github.com/panitaxx/gupshup-wabaapi/phone"
+)
+
+// Key groups conversations in the report
+type Key struct {
+	App      string `json:"app"`
+	Category string `json:"category"`
+	Country  string `json:"country"`
+	// Day is the day the conversation started, as 2006-01-02. It is taken from
+	// the conversation expiry, 24 hours after the start, or from the callback
+	// time when Gupshup does not send it
+	Day string `json:"day"`
+}
+
+// Entry is a line of the report
+type Entry struct {
+	Key
+	Conversations int `json:"conversations"`
+	// Billable is the number of billable conversations, the rest are free
+	Billable int `json:"billable"`
+	// Unpriced is the number of billable conversations without price in the table
+	Unpriced int     `json:"unpriced"`
+	Cost     float64 `json:"cost"`
+	Currency string  `json:"currency"`
+}
+
+// conversationWindow is the duration of a conversation
+const conversationWindow = 24 * time.Hour
+
+// Ledger counts the conversations of message-events, once per conversation id,
+// and prices them with Prices. The ids are kept until their conversation
+// expires. It is safe for concurrent use
+type Ledger struct {
+	Prices PriceTable
+	// Location of the report days, UTC when nil
+	Location *time.Location
+
+	mu sync.Mutex
+	// conversations has the expiry of the counted conversation ids
+	conversations map[string]time.Time
+	nextSweep     time.Time
+	entries       map[Key]*Entry
+}
+
+// sweepInterval is how often, in callback time, expired ids are dropped
+const sweepInterval = time.Hour
+
+// Handle adds the conversation of a message-event. Other callbacks are ignored
+// so it can be used as a WebhookHandler Handle func
+func (l *Ledger) Handle(msg *wabaapi.InboundMessage) error {
+	ev, ok := msg.AsMessageEvent()
+	if !ok {
+		return nil
+	}
+	l.Add(msg.App, msg.Timestamp, ev)
+	return nil
+}
+
+// Add adds the conversation of ev, received at for app. Events without
+// conversation or of a conversation already counted are ignored. Categories
+// are lower cased, like ReadPricesCSV does
+func (l *Ledger) Add(app string, at time.Time, ev wabaapi.MessageEventPayload) {
+	if ev.Conversation == nil || ev.Conversation.ID == "" {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, ok := l.conversations[ev.Conversation.ID]; ok {
+		return
+	}
+	if l.conversations == nil {
+		l.conversations = map[string]time.Time{}
+		l.entries = map[Key]*Entry{}
+	}
+	l.sweep(at)
+	expires := ev.Conversation.ExpiresAt
+	if expires.IsZero() {
+		expires = at.Add(conversationWindow)
+	}
+	l.conversations[ev.Conversation.ID] = expires
+
+	loc := l.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+	started := at
+	if !ev.Conversation.ExpiresAt.IsZero() {
+		started = ev.Conversation.ExpiresAt.Add(-conversationWindow)
+	}
+	category := ev.Conversation.Type
+	billable := true
+	if ev.Pricing != nil {
+		if ev.Pricing.Category != "" {
+			category = ev.Pricing.Category
+		}
+		billable = ev.Pricing.IsBillable()
+	}
+	key := Key{
+		App:      app,
+		Category: strings.ToLower(category),
+		Country:  phone.RegionOf(ev.Destination),
+		Day:      started.In(loc).Format("2006-01-02"),
+	}
+
+	entry, ok := l.entries[key]
+	if !ok {
+		entry = &Entry{Key: key}
+		if l.Prices != nil {
+			entry.Currency = l.Prices.Currency()
+		}
+		l.entries[key] = entry
+	}
+	entry.Conversations++
+	if !billable {
+		return
+	}
+	entry.Billable++
+
+	var price float64
+	priced := false
+	if l.Prices != nil {
+		price, priced = l.Prices.Price(key.Category, key.Country, started.In(loc))
+	}
+	if !priced {
+		entry.Unpriced++
+		return
+	}
+	entry.Cost += price
+}
+
+// sweep drops the ids of the conversations expired at now, at most once
+// every sweepInterval
+func (l *Ledger) sweep(now time.Time) {
+	if now.Before(l.nextSweep) {
+		return
+	}
+	for id, expires := range l.conversations {
+		if expires.Before(now) {
+			delete(l.conversations, id)
+		}
+	}
+	l.nextSweep = now.Add(sweepInterval)
+}
+
+// Report returns the entries sorted by day, app, category and country
+func (l *Ledger) Report() []Entry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	res := make([]Entry, 0, len(l.entries))
+	for _, e := range l.entries {
+		res = append(res, *e)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		a, b := res[i].Key, res[j].Key
+		if a.Day != b.Day {
+			return a.Day < b.Day
+		}
+		if a.App != b.App {
+			return a.App < b.App
+		}
+		if a.Category != b.Category {
+			return a.Category < b.Category
+		}
+		return a.Country < b.Country
+	})
+	return res
+}
+
+// WriteJSON writes the report as a JSON array of entries
+func (l *Ledger) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return merry.Wrap(enc.Encode(l.Report()))
+}
+
+// WriteCSV writes the report as CSV with a header
+func (l *Ledger) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"day", "app", "category", "country", "conversations", "billable", "unpriced", "cost", "currency"}); err != nil {
+		return merry.Wrap(err)
+	}
+
+	for _, e := range l.Report() {
+		record := []string{e.Day, e.App, e.Category, e.Country, strconv.Itoa(e.Conversations), strconv.Itoa(e.Billable),
+			strconv.Itoa(e.Unpriced), strconv.FormatFloat(e.Cost, 'f', 4, 64), e.Currency}
+		if err := cw.Write(record); err != nil {
+			return merry.Wrap(err)
+		}
+	}
+
+	cw.Flush()
+	return merry.Wrap(cw.Error())
+}
@@ -0,0 +1,123 @@
+// Package billing accounts WhatsApp conversations from message-event callbacks
+// and prices them, to reconcile Gupshup invoices.
+package billing
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ansel1/merry"
+)
+
+// AnyCountry is the country of the prices that apply to countries without their own
+const AnyCountry = "*"
+
+// PriceTable prices conversations
+type PriceTable interface {
+	// Price returns the price of a conversation of category with a user of
+	// country, an ISO 3166 alpha-2 code, started on day
+	Price(category string, country string, day time.Time) (price float64, ok bool)
+	// Currency is the ISO 4217 currency of the prices
+	Currency() string
+}
+
+// StaticPrices is a PriceTable with fixed prices by category and country
+type StaticPrices struct {
+	CurrencyCode string
+	// Prices by category and country, the AnyCountry price applies to the rest
+	Prices map[string]map[string]float64
+}
+
+func (sp *StaticPrices) Price(category string, country string, day time.Time) (float64, bool) {
+	byCountry, ok := sp.Prices[category]
+	if !ok {
+		return 0, false
+	}
+	if price, ok := byCountry[country]; ok {
+		return price, true
+	}
+	price, ok := byCountry[AnyCountry]
+	return price, ok
+}
+
+func (sp *StaticPrices) Currency() string {
+	return sp.CurrencyCode
+}
+
+// ReadPricesCSV reads a price table with a header and the columns category,
+// country and price. The country may be AnyCountry
+func ReadPricesCSV(r io.Reader, currency string) (*StaticPrices, error) {
+	records, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return nil, merry.Wrap(err)
+	}
+	if len(records) == 0 {
+		return nil, merry.New("empty price table")
+	}
+
+	sp := &StaticPrices{CurrencyCode: currency, Prices: map[string]map[string]float64{}}
+	for i, rec := range records[1:] {
+		if len(rec) != 3 {
+			return nil, merry.Errorf("row %d: expected category, country and price", i+2)
+		}
+		price, err := strconv.ParseFloat(strings.TrimSpace(rec[2]), 64)
+		if err != nil {
+			return nil, merry.Errorf("row %d: invalid price %q", i+2, rec[2])
+		}
+		category := strings.ToLower(strings.TrimSpace(rec[0]))
+		if sp.Prices[category] == nil {
+			sp.Prices[category] = map[string]float64{}
+		}
+		sp.Prices[category][strings.ToUpper(strings.TrimSpace(rec[1]))] = price
+	}
+	return sp, nil
+}
+
+// DatedPrices uses the table in effect on the day of the conversation, tables
+// must be sorted by From
+type DatedPrices []DatedTable
+
+// DatedTable is a PriceTable in effect from a day
+type DatedTable struct {
+	From  time.Time
+	Table PriceTable
+}
+
+func (dp DatedPrices) Price(category string, country string, day time.Time) (float64, bool) {
+	var table PriceTable
+	for _, dt := range dp {
+		if dt.From.After(day) {
+			break
+		}
+		table = dt.Table
+	}
+	if table == nil {
+		return 0, false
+	}
+	return table.Price(category, country, day)
+}
+
+// Validate checks the tables are sorted by From and share one currency
+func (dp DatedPrices) Validate() error {
+	for i := 1; i < len(dp); i++ {
+		if dp[i].From.Before(dp[i-1].From) {
+			return merry.Errorf("price table %d is not sorted by From", i)
+		}
+		if cur, first := dp[i].Table.Currency(), dp[0].Table.Currency(); cur != first {
+			return merry.Errorf("price table %d uses %s, the first table uses %s", i, cur, first)
+		}
+	}
+	return nil
+}
+
+// Currency is the currency of the first table. Reports show every cost in it,
+// so all tables must use the same, see Validate
+func (dp DatedPrices) Currency() string {
+	if len(dp) == 0 {
+		return ""
+	}
+	return dp[0].Table.Currency()
+}
@@ -0,0 +1,387 @@
+// Package campaign sends a template to a list of recipients with throttling
+// and builds a per-recipient delivery report from Gupshup's message-events.
+package campaign
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ansel1/merry"
+	wabaapi "github.com/panitaxx/gupshup-wabaapi"
+)
+
+// Sender submits a message and returns its id. *wabaapi.Client implements it.
+type Sender interface {
+	Send(ctx context.Context, values url.Values) (string, error)
+}
+
+// Status is the delivery status of a recipient
+type Status string
+
+const (
+	StatusPending   Status = "pending"
+	StatusSubmitted Status = "submitted"
+	StatusEnqueued  Status = "enqueued"
+	StatusSent      Status = "sent"
+	StatusDelivered Status = "delivered"
+	StatusRead      Status = "read"
+	StatusFailed    Status = "failed"
+)
+
+// rank orders statuses so late events never downgrade a recipient
+func (s Status) rank() int {
+	switch s {
+	case StatusSubmitted:
+		return 1
+	case StatusEnqueued:
+		return 2
+	case StatusSent:
+		return 3
+	case StatusDelivered:
+		return 4
+	case StatusRead:
+		return 5
+	case StatusFailed:
+		return 6
+	}
+	return 0
+}
+
+// Result is the report line of a recipient
+type Result struct {
+	Recipient
+	MessageID string
+	Status    Status
+	Reason    string
+	UpdatedAt time.Time
+}
+
+// RowError is a recipient that failed validation
+type RowError struct {
+	Row         int
+	Destination string
+	Err         error
+}
+
+func (re RowError) Error() string {
+	return fmt.Sprintf("row %d (%s): %s", re.Row, re.Destination, re.Err)
+}
+
+// RowErrors is returned by Validate with every invalid row
+type RowErrors []RowError
+
+func (res RowErrors) Error() string {
+	msgs := make([]string, 0, len(res))
+	for _, re := range res {
+		msgs = append(msgs, re.Error())
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// MaxEarlyEvents is the number of message-events kept for messages whose Send
+// has not returned yet. The oldest are dropped first
+var MaxEarlyEvents = 1000
+
+// Campaign sends the template TemplateID to every recipient using Message as
+// the defaults for channel, source and source name. Rate is the maximum number
+// of messages per second, zero means no throttling.
+type Campaign struct {
+	Message    wabaapi.OutboundMessage
+	TemplateID string
+	Recipients []Recipient
+	Sender     Sender
+	Rate       float64
+	// App, when set, makes HandleEvent ignore the callbacks of other apps
+	// sharing the webhook
+	App string
+
+	mu         sync.Mutex
+	results    []Result
+	byID       map[string]int
+	early      map[string]wabaapi.MessageEventPayload
+	earlyOrder []string
+	next       int
+	paused     bool
+	resume     chan struct{}
+}
+
+func (c *Campaign) message(r Recipient) *wabaapi.OutboundMessage {
+	om := c.Message
+	om.Destination = r.Destination
+	return &om
+}
+
+// Validate checks every recipient up front and returns RowErrors with all the invalid rows
+func (c *Campaign) Validate() error {
+	if c.TemplateID == "" {
+		return merry.New("template id not specified")
+	}
+
+	var errs RowErrors
+	for _, r := range c.Recipients {
+		if err := c.message(r).Validate(); err != nil {
+			errs = append(errs, RowError{Row: r.Row, Destination: r.Destination, Err: err})
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func (c *Campaign) init() {
+	if c.results != nil {
+		return
+	}
+	c.results = make([]Result, len(c.Recipients))
+	for i, r := range c.Recipients {
+		c.results[i] = Result{Recipient: r, Status: StatusPending}
+	}
+	c.byID = map[string]int{}
+	c.early = map[string]wabaapi.MessageEventPayload{}
+}
+
+// Run validates the campaign and sends to every recipient not sent yet.
+// When ctx is cancelled Run returns and a later call continues where it stopped
+func (c *Campaign) Run(ctx context.Context) error {
+	if c.Sender == nil {
+		return merry.New("campaign sender not configured")
+	}
+	if err := c.Validate(); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	c.init()
+	c.mu.Unlock()
+
+	var tick <-chan time.Time
+	if c.Rate > 0 {
+		ticker := time.NewTicker(time.Duration(float64(time.Second) / c.Rate))
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		if err := c.waitResume(ctx); err != nil {
+			return err
+		}
+
+		c.mu.Lock()
+		i := c.next
+		c.mu.Unlock()
+		if i >= len(c.Recipients) {
+			return nil
+		}
+
+		if tick != nil {
+			select {
+			case <-tick:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+
+		//a send interrupted by ctx is retried by the next Run
+		if err := c.send(ctx, i); err != nil {
+			return err
+		}
+
+		c.mu.Lock()
+		c.next = i + 1
+		c.mu.Unlock()
+	}
+}
+
+// send sends to recipient i and records the result. It only returns the ctx
+// error when ctx ends during the send, leaving the recipient pending
+func (c *Campaign) send(ctx context.Context, i int) error {
+	r := c.Recipients[i]
+	values, err := c.message(r).Template(c.TemplateID, r.Params)
+	var id string
+	if err == nil {
+		id, err = c.Sender.Send(ctx, values)
+		if err != nil && ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	res := &c.results[i]
+	res.UpdatedAt = time.Now()
+	if err != nil {
+		res.Status = StatusFailed
+		res.Reason = err.Error()
+		return nil
+	}
+
+	res.MessageID = id
+	res.Status = StatusSubmitted
+	c.byID[id] = i
+	if ev, ok := c.early[id]; ok {
+		delete(c.early, id)
+		c.apply(i, ev)
+	}
+	return nil
+}
+
+func (c *Campaign) waitResume(ctx context.Context) error {
+	for {
+		c.mu.Lock()
+		if !c.paused {
+			c.mu.Unlock()
+			return nil
+		}
+		resume := c.resume
+		c.mu.Unlock()
+
+		select {
+		case <-resume:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Pause stops sending after the message in flight
+func (c *Campaign) Pause() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.paused {
+		c.paused = true
+		c.resume = make(chan struct{})
+	}
+}
+
+// Resume continues a paused campaign
+func (c *Campaign) Resume() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.paused {
+		c.paused = false
+		close(c.resume)
+	}
+}
+
+// Paused reports if the campaign is paused
+func (c *Campaign) Paused() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.paused
+}
+
+// HandleEvent updates the report with a message-event. Other inbound messages are ignored,
+// so it can be used directly as the WebhookHandler Handle func
+func (c *Campaign) HandleEvent(msg *wabaapi.InboundMessage) error {
+	ev, ok := msg.AsMessageEvent()
+	if !ok || (c.App != "" && msg.App != c.App) {
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.init()
+
+	//Gupshup sends its own id in gsId once the whatsapp id is known
+	for _, id := range []string{ev.GSID, ev.ID} {
+		if i, ok := c.byID[id]; ok && id != "" {
+			c.apply(i, ev)
+			return nil
+		}
+	}
+
+	//the event may arrive before Send returns, keyed by the id Send returns
+	id := ev.GSID
+	if id == "" {
+		id = ev.ID
+	}
+	if id != "" {
+		c.addEarly(id, ev)
+	}
+	return nil
+}
+
+// addEarly keeps the most advanced early event of a message, dropping the
+// oldest messages over MaxEarlyEvents
+func (c *Campaign) addEarly(id string, ev wabaapi.MessageEventPayload) {
+	if prev, ok := c.early[id]; ok {
+		if Status(ev.Type).rank() > Status(prev.Type).rank() {
+			c.early[id] = ev
+		}
+		return
+	}
+
+	for len(c.earlyOrder) > 0 && len(c.early) >= MaxEarlyEvents {
+		delete(c.early, c.earlyOrder[0])
+		c.earlyOrder = c.earlyOrder[1:]
+	}
+	c.early[id] = ev
+	c.earlyOrder = append(c.earlyOrder, id)
+
+	//ids matched by send are still in the order, compact it now and then
+	if len(c.earlyOrder) > 2*len(c.early)+MaxEarlyEvents {
+		order := c.earlyOrder[:0]
+		for _, id := range c.earlyOrder {
+			if _, ok := c.early[id]; ok {
+				order = append(order, id)
+			}
+		}
+		c.earlyOrder = order
+	}
+}
+
+func (c *Campaign) apply(i int, ev wabaapi.MessageEventPayload) {
+	res := &c.results[i]
+	status := Status(ev.Type)
+	if status.rank() == 0 || status.rank() <= res.Status.rank() {
+		return
+	}
+
+	res.Status = status
+	res.UpdatedAt = time.Now()
+	if err := ev.GetError(); err != nil {
+		res.Reason = err.Error()
+	}
+}
+
+// Report returns a copy of the per-recipient results
+func (c *Campaign) Report() []Result {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.init()
+
+	out := make([]Result, len(c.results))
+	copy(out, c.results)
+	return out
+}
+
+// WriteReport writes the report as CSV
+func (c *Campaign) WriteReport(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"row", "destination", "message_id", "status", "reason", "updated_at"}); err != nil {
+		return merry.Wrap(err)
+	}
+
+	for _, res := range c.Report() {
+		updated := ""
+		if !res.UpdatedAt.IsZero() {
+			updated = res.UpdatedAt.Format(time.RFC3339)
+		}
+		record := []string{strconv.Itoa(res.Row), res.Destination, res.MessageID, string(res.Status), res.Reason, updated}
+		if err := cw.Write(record); err != nil {
+			return merry.Wrap(err)
+		}
+	}
+
+	cw.Flush()
+	return merry.Wrap(cw.Error())
+}
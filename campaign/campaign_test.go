@@ -0,0 +1,145 @@
+package campaign
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"testing"
+
+	wabaapi "github.com/panitaxx/gupshup-wabaapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeSender struct {
+	sent []url.Values
+}
+
+func (fs *fakeSender) Send(ctx context.Context, values url.Values) (string, error) {
+	fs.sent = append(fs.sent, values)
+	return fmt.Sprintf("gs-%d", len(fs.sent)), nil
+}
+
+func event(t *testing.T, raw string) *wabaapi.InboundMessage {
+	var msg wabaapi.InboundMessage
+	require.NoError(t, json.Unmarshal([]byte(raw), &msg))
+	return &msg
+}
+
+func TestCampaignRun(t *testing.T) {
+	recipients, err := ReadCSV(strings.NewReader("destination,name,code\n+34600000001,Ana,A1\n+34600000002,Luis,B2\n"))
+	require.NoError(t, err)
+
+	sender := &fakeSender{}
+	c := &Campaign{
+		Message:    wabaapi.OutboundMessage{Channel: "whatsapp", Source: "15555555555", SourceName: "app"},
+		TemplateID: "tmpl-1",
+		Recipients: recipients,
+		Sender:     sender,
+	}
+	require.NoError(t, c.Run(context.Background()))
+	require.Len(t, sender.sent, 2)
+	assert.JSONEq(t, `{"id":"tmpl-1","params":["Luis","B2"]}`, sender.sent[1].Get("template"))
+
+	assert.NoError(t, c.HandleEvent(event(t, `{"app":"app","timestamp":1639000000000,"type":"message-event","payload":{"id":"wa-1","gsId":"gs-1","type":"read","destination":"34600000001","payload":{"ts":1639000000}}}`)))
+	//a late delivered event must not downgrade read
+	assert.NoError(t, c.HandleEvent(event(t, `{"app":"app","timestamp":1639000000000,"type":"message-event","payload":{"id":"wa-1","gsId":"gs-1","type":"delivered","destination":"34600000001","payload":{"ts":1639000000}}}`)))
+	assert.NoError(t, c.HandleEvent(event(t, `{"app":"app","timestamp":1639000000000,"type":"message-event","payload":{"id":"gs-2","type":"failed","destination":"34600000002","payload":{"code":1002,"reason":"Number does not exist on WhatsApp"}}}`)))
+
+	report := c.Report()
+	assert.Equal(t, StatusRead, report[0].Status)
+	assert.Equal(t, StatusFailed, report[1].Status)
+	assert.Contains(t, report[1].Reason, "Number does not exist")
+
+	var buf bytes.Buffer
+	require.NoError(t, c.WriteReport(&buf))
+	assert.Contains(t, buf.String(), "3,+34600000002,gs-2,failed")
+}
+
+func TestCampaignValidate(t *testing.T) {
+	c := &Campaign{
+		Message:    wabaapi.OutboundMessage{Channel: "whatsapp", Source: "15555555555", SourceName: "app"},
+		TemplateID: "tmpl-1",
+		Recipients: []Recipient{{Row: 2, Destination: "+34600000001"}, {Row: 3, Destination: "not a phone"}},
+		Sender:     &fakeSender{},
+	}
+	err := c.Run(context.Background())
+	var rowErrs RowErrors
+	require.ErrorAs(t, err, &rowErrs)
+	assert.Len(t, rowErrs, 1)
+	assert.Equal(t, 3, rowErrs[0].Row)
+}
+
+// cancelSender cancels the run on the send number cancelAt and fails it with the ctx error
+type cancelSender struct {
+	fakeSender
+	cancelAt int
+	cancel   context.CancelFunc
+	calls    int
+}
+
+func (cs *cancelSender) Send(ctx context.Context, values url.Values) (string, error) {
+	cs.calls++
+	if cs.calls == cs.cancelAt {
+		cs.cancel()
+		return "", ctx.Err()
+	}
+	return cs.fakeSender.Send(ctx, values)
+}
+
+func TestCampaignCancelResume(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	sender := &cancelSender{cancelAt: 2, cancel: cancel}
+	c := &Campaign{
+		Message:    wabaapi.OutboundMessage{Channel: "whatsapp", Source: "15555555555", SourceName: "app"},
+		TemplateID: "tmpl-1",
+		Recipients: []Recipient{{Row: 2, Destination: "+34600000001"}, {Row: 3, Destination: "+34600000002"}, {Row: 4, Destination: "+34600000003"}},
+		Sender:     sender,
+	}
+	assert.ErrorIs(t, c.Run(ctx), context.Canceled)
+	report := c.Report()
+	assert.Equal(t, StatusSubmitted, report[0].Status)
+	assert.Equal(t, StatusPending, report[1].Status)
+	assert.Empty(t, report[1].Reason)
+
+	require.NoError(t, c.Run(context.Background()))
+	require.Len(t, sender.sent, 3)
+	for _, res := range c.Report() {
+		assert.Equal(t, StatusSubmitted, res.Status, res.Destination)
+	}
+}
+
+func TestCampaignEarlyEvents(t *testing.T) {
+	c := &Campaign{
+		Message:    wabaapi.OutboundMessage{Channel: "whatsapp", Source: "15555555555", SourceName: "app"},
+		TemplateID: "tmpl-1",
+		Recipients: []Recipient{{Row: 2, Destination: "+34600000001"}},
+		Sender:     &fakeSender{},
+		App:        "app",
+	}
+	//events of gs-1 arriving before Send returns, sent carries the Gupshup id in gsId
+	assert.NoError(t, c.HandleEvent(event(t, `{"app":"app","timestamp":1639000000000,"type":"message-event","payload":{"id":"gs-1","type":"enqueued","destination":"34600000001","payload":{"whatsappMessageId":"wa-1","type":"session"}}}`)))
+	assert.NoError(t, c.HandleEvent(event(t, `{"app":"app","timestamp":1639000000000,"type":"message-event","payload":{"id":"wa-1","gsId":"gs-1","type":"sent","destination":"34600000001","payload":{"ts":1639000000}}}`)))
+	//other apps are ignored
+	assert.NoError(t, c.HandleEvent(event(t, `{"app":"other","timestamp":1639000000000,"type":"message-event","payload":{"id":"wa-2","gsId":"gs-1","type":"read","destination":"34600000001","payload":{"ts":1639000000}}}`)))
+
+	require.NoError(t, c.Run(context.Background()))
+	assert.Equal(t, StatusSent, c.Report()[0].Status)
+}
+
+func TestCampaignEarlyEventsBounded(t *testing.T) {
+	defer func(max int) { MaxEarlyEvents = max }(MaxEarlyEvents)
+	MaxEarlyEvents = 10
+
+	c := &Campaign{}
+	for i := 0; i < 100; i++ {
+		raw := fmt.Sprintf(`{"app":"app","timestamp":1639000000000,"type":"message-event","payload":{"id":"wa-%d","gsId":"gs-%d","type":"sent","destination":"34600000001","payload":{"ts":1639000000}}}`, i, i)
+		assert.NoError(t, c.HandleEvent(event(t, raw)))
+	}
+	assert.Len(t, c.early, 10)
+	assert.Contains(t, c.early, "gs-99")
+	assert.NotContains(t, c.early, "gs-0")
+}
@@ -0,0 +1,56 @@
+package campaign
+
+import (
+	"encoding/csv"
+	"io"
+	"strings"
+
+	"github.com/ansel1/merry"
+)
+
+// Recipient is a single row of a campaign: the destination and the template
+// params for that destination
+type Recipient struct {
+	Row         int
+	Destination string
+	Params      []string
+}
+
+// ReadCSV reads recipients from a CSV with a header row. The first column is the
+// destination and every other column is a template param, in order
+func ReadCSV(r io.Reader) ([]Recipient, error) {
+	cr := csv.NewReader(r)
+	cr.TrimLeadingSpace = true
+
+	header, err := cr.Read()
+	if err == io.EOF {
+		return nil, merry.New("csv is empty")
+	}
+	if err != nil {
+		return nil, merry.Wrap(err)
+	}
+	if len(header) == 0 || strings.TrimSpace(header[0]) == "" {
+		return nil, merry.New("csv header must start with the destination column")
+	}
+
+	var recipients []Recipient
+	row := 1
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		row++
+		if err != nil {
+			return nil, merry.Errorf("row %d: %s", row, err)
+		}
+
+		recipients = append(recipients, Recipient{
+			Row:         row,
+			Destination: strings.TrimSpace(record[0]),
+			Params:      record[1:],
+		})
+	}
+
+	return recipients, nil
+}
@@ -0,0 +1,213 @@
+package wabaapi
+
+import (
+	"encoding/json"
+	"net/url"
+
+	"github.com/ansel1/merry"
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+)
+
+// WhatsApp limits of multi-product messages
+const (
+	MaxProductSections = 10
+	MaxProducts        = 30
+)
+
+// Product creates a single product message of a catalog
+func (om *OutboundMessage) Product(pm ProductMessage) (url.Values, error) {
+	return om.Build(pm)
+}
+
+// MultiProduct creates a message with up to MaxProducts products of a catalog
+// in up to MaxProductSections sections
+func (om *OutboundMessage) MultiProduct(mpm MultiProductMessage) (url.Values, error) {
+	return om.Build(mpm)
+}
+
+type catalogText struct {
+	Type string `json:"type,omitempty"`
+	Text string `json:"text"`
+}
+
+func newCatalogText(typ string, text string) *catalogText {
+	if text == "" {
+		return nil
+	}
+	return &catalogText{Type: typ, Text: text}
+}
+
+func (t *catalogText) text() string {
+	if t == nil {
+		return ""
+	}
+	return t.Text
+}
+
+// ProductMessage shows a product of a catalog. Body and Footer are optional
+type ProductMessage struct {
+	CatalogID string
+	// ProductID is the product retailer id, the SKU of the catalog item
+	ProductID string
+	Body      string
+	Footer    string
+}
+
+func (pm ProductMessage) MessageType() string { return "product_details" }
+func (pm ProductMessage) Encode() url.Values  { return encodeMessage(pm) }
+
+func (pm ProductMessage) Validate() error {
+	return validation.ValidateStruct(&pm,
+		validation.Field(&pm.CatalogID, validation.Required),
+		validation.Field(&pm.ProductID, validation.Required),
+		validation.Field(&pm.Body, validation.RuneLength(0, 1024)),
+		validation.Field(&pm.Footer, validation.RuneLength(0, 60)),
+	)
+}
+
+type productMessage struct {
+	Type      string       `json:"type"`
+	SubType   string       `json:"subType"`
+	CatalogID string       `json:"catalogId"`
+	ProductID string       `json:"productId"`
+	Body      *catalogText `json:"body,omitempty"`
+	Footer    *catalogText `json:"footer,omitempty"`
+}
+
+func (pm ProductMessage) MarshalJSON() ([]byte, error) {
+	return json.Marshal(productMessage{
+		Type:      pm.MessageType(),
+		SubType:   "product",
+		CatalogID: pm.CatalogID,
+		ProductID: pm.ProductID,
+		Body:      newCatalogText("", pm.Body),
+		Footer:    newCatalogText("", pm.Footer),
+	})
+}
+
+func (pm *ProductMessage) UnmarshalJSON(data []byte) error {
+	var tmp productMessage
+	if err := json.Unmarshal(data, &tmp); err != nil {
+		return err
+	}
+	*pm = ProductMessage{
+		CatalogID: tmp.CatalogID,
+		ProductID: tmp.ProductID,
+		Body:      tmp.Body.text(),
+		Footer:    tmp.Footer.text(),
+	}
+	return nil
+}
+
+// MultiProductMessage shows products of a catalog grouped in sections.
+// Header and Body are required, sections need a title when there is more than one
+type MultiProductMessage struct {
+	CatalogID string
+	Header    string
+	Body      string
+	Footer    string
+	Sections  []ProductSection
+}
+
+// ProductSection is a titled group of products, by product retailer id
+type ProductSection struct {
+	Title      string
+	ProductIDs []string
+}
+
+func (mpm MultiProductMessage) MessageType() string { return "product_details" }
+func (mpm MultiProductMessage) Encode() url.Values  { return encodeMessage(mpm) }
+
+func (mpm MultiProductMessage) Validate() error {
+	err := validation.ValidateStruct(&mpm,
+		validation.Field(&mpm.CatalogID, validation.Required),
+		validation.Field(&mpm.Header, validation.Required, validation.RuneLength(1, 60)),
+		validation.Field(&mpm.Body, validation.Required, validation.RuneLength(1, 1024)),
+		validation.Field(&mpm.Footer, validation.RuneLength(0, 60)),
+		validation.Field(&mpm.Sections, validation.Required, validation.Length(1, MaxProductSections)),
+	)
+	if err != nil {
+		return err
+	}
+
+	var total int
+	for i, s := range mpm.Sections {
+		if len(mpm.Sections) > 1 && s.Title == "" {
+			return merry.Errorf("section %d: title is required with more than one section", i)
+		}
+		if err := s.Validate(); err != nil {
+			return merry.Prependf(err, "section %d", i)
+		}
+		total += len(s.ProductIDs)
+	}
+	if total > MaxProducts {
+		return merry.Errorf("%d products exceed the limit of %d", total, MaxProducts)
+	}
+	return nil
+}
+
+func (s ProductSection) Validate() error {
+	return validation.ValidateStruct(&s,
+		validation.Field(&s.Title, validation.RuneLength(0, 24)),
+		validation.Field(&s.ProductIDs, validation.Required, validation.Each(validation.Required)),
+	)
+}
+
+type productSection struct {
+	Title    string `json:"title,omitempty"`
+	Products []struct {
+		ProductID string `json:"productId"`
+	} `json:"products"`
+}
+
+type multiProductMessage struct {
+	Type      string           `json:"type"`
+	SubType   string           `json:"subType"`
+	CatalogID string           `json:"catalogId"`
+	Header    *catalogText     `json:"header,omitempty"`
+	Body      *catalogText     `json:"body,omitempty"`
+	Footer    *catalogText     `json:"footer,omitempty"`
+	Sections  []productSection `json:"sections"`
+}
+
+func (mpm MultiProductMessage) MarshalJSON() ([]byte, error) {
+	tmp := multiProductMessage{
+		Type:      mpm.MessageType(),
+		SubType:   "product_list",
+		CatalogID: mpm.CatalogID,
+		Header:    newCatalogText("text", mpm.Header),
+		Body:      newCatalogText("", mpm.Body),
+		Footer:    newCatalogText("", mpm.Footer),
+		Sections:  make([]productSection, len(mpm.Sections)),
+	}
+	for i, s := range mpm.Sections {
+		tmp.Sections[i].Title = s.Title
+		for _, id := range s.ProductIDs {
+			tmp.Sections[i].Products = append(tmp.Sections[i].Products, struct {
+				ProductID string `json:"productId"`
+			}{id})
+		}
+	}
+	return json.Marshal(tmp)
+}
+
+func (mpm *MultiProductMessage) UnmarshalJSON(data []byte) error {
+	var tmp multiProductMessage
+	if err := json.Unmarshal(data, &tmp); err != nil {
+		return err
+	}
+	*mpm = MultiProductMessage{
+		CatalogID: tmp.CatalogID,
+		Header:    tmp.Header.text(),
+		Body:      tmp.Body.text(),
+		Footer:    tmp.Footer.text(),
+	}
+	for _, s := range tmp.Sections {
+		section := ProductSection{Title: s.Title}
+		for _, p := range s.Products {
+			section.ProductIDs = append(section.ProductIDs, p.ProductID)
+		}
+		mpm.Sections = append(mpm.Sections, section)
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package wabaapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProductMessageMarshal(t *testing.T) {
+	val, err := json.Marshal(ProductMessage{CatalogID: "cat", ProductID: "sku-1", Footer: "free shipping"})
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"type":"product_details","subType":"product","catalogId":"cat","productId":"sku-1","footer":{"text":"free shipping"}}`, string(val))
+
+	val, err = json.Marshal(MultiProductMessage{CatalogID: "cat", Header: "Summer", Body: "new arrivals",
+		Sections: []ProductSection{{Title: "Shirts", ProductIDs: []string{"sku-1", "sku-2"}}}})
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"type":"product_details","subType":"product_list","catalogId":"cat",
+		"header":{"type":"text","text":"Summer"},"body":{"text":"new arrivals"},
+		"sections":[{"title":"Shirts","products":[{"productId":"sku-1"},{"productId":"sku-2"}]}]}`, string(val))
+}
+
+func TestMultiProductValidate(t *testing.T) {
+	ids := func(n int) []string {
+		var res []string
+		for i := 0; i < n; i++ {
+			res = append(res, fmt.Sprintf("sku-%d", i))
+		}
+		return res
+	}
+	valid := MultiProductMessage{CatalogID: "cat", Header: "Summer", Body: "new arrivals",
+		Sections: []ProductSection{{ProductIDs: ids(30)}}}
+	assert.NoError(t, valid.Validate())
+
+	tooMany := valid
+	tooMany.Sections = []ProductSection{{Title: "a", ProductIDs: ids(20)}, {Title: "b", ProductIDs: ids(11)}}
+	assert.Error(t, tooMany.Validate())
+
+	untitled := valid
+	untitled.Sections = []ProductSection{{Title: "a", ProductIDs: ids(1)}, {ProductIDs: ids(1)}}
+	assert.Error(t, untitled.Validate())
+
+	sections := valid
+	sections.Sections = make([]ProductSection, 11)
+	for i := range sections.Sections {
+		sections.Sections[i] = ProductSection{Title: fmt.Sprint(i), ProductIDs: ids(1)}
+	}
+	assert.Error(t, sections.Validate())
+
+	noHeader := valid
+	noHeader.Header = ""
+	assert.Error(t, noHeader.Validate())
+
+	assert.Error(t, ProductMessage{CatalogID: "cat"}.Validate())
+}
@@ -0,0 +1,157 @@
+package wabaapi
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/ansel1/merry"
+)
+
+// DefaultBaseURL is the base URL of Gupshup's messaging API
+const DefaultBaseURL = "https://api.gupshup.io/sm/api/v1"
+
+// Client sends the values created by OutboundMessage to Gupshup.
+// The zero value is not usable, APIKey is required.
+type Client struct {
+	APIKey     string
+	BaseURL    string
+	HTTPClient *http.Client
+	Observer   Observer
+}
+
+// SendResponse is the body returned by Gupshup after a message is submitted
+type SendResponse struct {
+	Status    string `json:"status"`
+	MessageID string `json:"messageId"`
+	Message   string `json:"message"`
+}
+
+func (c *Client) baseURL() string {
+	if c.BaseURL == "" {
+		return DefaultBaseURL
+	}
+	return strings.TrimSuffix(c.BaseURL, "/")
+}
+
+// rootURL is the Gupshup API host, the BaseURL without the /sm/api/v1 path
+func (c *Client) rootURL() string {
+	return strings.TrimSuffix(c.baseURL(), "/sm/api/v1")
+}
+
+// v2URL is the base URL of the v2 API, next to the v1 BaseURL
+func (c *Client) v2URL() string {
+	return strings.TrimSuffix(c.baseURL(), "/v1") + "/v2"
+}
+
+func (c *Client) httpClient() *http.Client {
+	if c.HTTPClient == nil {
+		return &http.Client{Timeout: DefaultTimeout}
+	}
+	return c.HTTPClient
+}
+
+// Send posts the values to the message endpoint, or to the template endpoint
+// when the values were created by OutboundMessage.Template, and returns the message id
+func (c *Client) Send(ctx context.Context, values url.Values) (id string, err error) {
+	endpoint := "/msg"
+	if values.Get("template") != "" {
+		endpoint = "/template/msg"
+	}
+
+	status := 0
+	if c.Observer != nil {
+		var done func(int, error)
+		ctx, done = c.Observer.SendStart(ctx, messageType(values))
+		defer func() { done(status, err) }()
+	}
+
+	var resp SendResponse
+	status, err = c.do(ctx, http.MethodPost, endpoint, strings.NewReader(values.Encode()), "application/x-www-form-urlencoded", &resp)
+	if err != nil {
+		return "", err
+	}
+
+	if resp.MessageID == "" {
+		return "", merry.Errorf("message not submitted: %s %s", resp.Status, resp.Message)
+	}
+	return resp.MessageID, nil
+}
+
+// do sends a request to Gupshup, decodes the JSON response in out and returns the status code
+func (c *Client) do(ctx context.Context, method string, endpoint string, body io.Reader, contentType string, out interface{}) (int, error) {
+	return c.doURL(ctx, method, c.baseURL()+endpoint, body, contentType, out)
+}
+
+// doURL is do with an absolute URL, for endpoints outside the v1 API
+func (c *Client) doURL(ctx context.Context, method string, u string, body io.Reader, contentType string, out interface{}) (int, error) {
+	if c.APIKey == "" {
+		return 0, merry.New("client api key not configured")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, u, body)
+	if err != nil {
+		return 0, merry.Wrap(err)
+	}
+	req.Header.Set("apikey", c.APIKey)
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	return roundTrip(c.httpClient(), req, out)
+}
+
+// roundTrip sends req, decodes the JSON response in out and returns the status
+// code. Non 2xx responses are errors with the Gupshup message and the status code
+func roundTrip(hc *http.Client, req *http.Request, out interface{}) (int, error) {
+	resp, err := hc.Do(req)
+	if err != nil {
+		return 0, merry.Wrap(err)
+	}
+	defer resp.Body.Close()
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return resp.StatusCode, merry.Wrap(err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, responseError(resp.StatusCode, data)
+	}
+
+	if out == nil {
+		return resp.StatusCode, nil
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return resp.StatusCode, merry.Errorf("failed to parse gupshup response: %s", err)
+	}
+	return resp.StatusCode, nil
+}
+
+// responseError is the error of a failed request with the Gupshup message of body
+func responseError(status int, body []byte) error {
+	var apiErr struct {
+		Status  string `json:"status"`
+		Message string `json:"message"`
+	}
+	msg := strings.TrimSpace(string(body))
+	if json.Unmarshal(body, &apiErr) == nil && apiErr.Message != "" {
+		msg = apiErr.Message
+	}
+	return merry.Errorf("gupshup request failed:[%d] %s", status, msg).WithHTTPCode(status)
+}
+
+// Templates lists the templates of app
+func (c *Client) Templates(ctx context.Context, app string) ([]TemplateInfo, error) {
+	var resp struct {
+		Status    string         `json:"status"`
+		Templates []TemplateInfo `json:"templates"`
+	}
+	if _, err := c.do(ctx, http.MethodGet, "/template/list/"+url.PathEscape(app), nil, "", &resp); err != nil {
+		return nil, err
+	}
+	return resp.Templates, nil
+}
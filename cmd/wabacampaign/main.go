@@ -0,0 +1,125 @@
+// Command wabacampaign sends a template to every destination of a CSV file and
+// writes a delivery report built from the message-events received on -listen.
+//
+// Type "pause" or "resume" on stdin to control the campaign while it runs.
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	wabaapi "github.com/panitaxx/gupshup-wabaapi"
+	"github.com/panitaxx/gupshup-wabaapi/campaign"
+)
+
+func main() {
+	csvPath := flag.String("csv", "", "CSV file with the destination in the first column and template params in the rest")
+	templateID := flag.String("template", "", "template id")
+	apiKey := flag.String("apikey", os.Getenv("GUPSHUP_APIKEY"), "Gupshup api key")
+	source := flag.String("source", "", "source phone number")
+	sourceName := flag.String("srcname", "", "Gupshup app name")
+	rate := flag.Float64("rate", 10, "messages per second, 0 disables throttling")
+	listen := flag.String("listen", "", "address for the message-event webhook, e.g. :8080")
+	wait := flag.Duration("wait", time.Minute, "time to keep collecting events after the last message is sent")
+	reportPath := flag.String("report", "report.csv", "report output file")
+	flag.Parse()
+
+	if err := run(*csvPath, *templateID, *apiKey, *source, *sourceName, *rate, *listen, *wait, *reportPath); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func run(csvPath, templateID, apiKey, source, sourceName string, rate float64, listen string, wait time.Duration, reportPath string) error {
+	f, err := os.Open(csvPath)
+	if err != nil {
+		return err
+	}
+	recipients, err := campaign.ReadCSV(f)
+	f.Close()
+	if err != nil {
+		return err
+	}
+
+	c := &campaign.Campaign{
+		Message: wabaapi.OutboundMessage{
+			Channel:    "whatsapp",
+			Source:     source,
+			SourceName: sourceName,
+		},
+		TemplateID: templateID,
+		Recipients: recipients,
+		Sender:     &wabaapi.Client{APIKey: apiKey},
+		Rate:       rate,
+	}
+
+	if err := c.Validate(); err != nil {
+		var rowErrs campaign.RowErrors
+		if errors.As(err, &rowErrs) {
+			for _, re := range rowErrs {
+				fmt.Fprintln(os.Stderr, re)
+			}
+			return fmt.Errorf("%d invalid rows, nothing was sent", len(rowErrs))
+		}
+		return err
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	if listen != "" {
+		srv := &http.Server{Addr: listen, Handler: &wabaapi.WebhookHandler{Handle: c.HandleEvent}}
+		go func() {
+			if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				log.Println("webhook listener:", err)
+			}
+		}()
+		defer srv.Close()
+	}
+
+	go readCommands(c)
+
+	log.Printf("sending %s to %d recipients", templateID, len(recipients))
+	runErr := c.Run(ctx)
+	if runErr == nil && listen != "" {
+		log.Printf("all messages sent, collecting events for %s", wait)
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+		}
+	}
+
+	out, err := os.Create(reportPath)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	if err := c.WriteReport(out); err != nil {
+		return err
+	}
+	log.Printf("report written to %s", reportPath)
+
+	return runErr
+}
+
+func readCommands(c *campaign.Campaign) {
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		switch strings.TrimSpace(scanner.Text()) {
+		case "pause":
+			c.Pause()
+			log.Println("paused")
+		case "resume":
+			c.Resume()
+			log.Println("resumed")
+		}
+	}
+}
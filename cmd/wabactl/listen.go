@@ -0,0 +1,33 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	wabaapi "github.com/panitaxx/gupshup-wabaapi"
+)
+
+func listen(args []string) error {
+	var addr, path string
+	fs := flag.NewFlagSet("listen", flag.ExitOnError)
+	fs.StringVar(&addr, "addr", ":8080", "listen address")
+	fs.StringVar(&path, "path", "/", "webhook path")
+	_ = fs.Parse(args)
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+
+	mux := http.NewServeMux()
+	mux.Handle(path, &wabaapi.WebhookHandler{Handle: func(msg *wabaapi.InboundMessage) error {
+		fmt.Printf("--- %s %s %T\n", msg.Timestamp.Format(time.RFC3339), msg.Type, msg.Payload)
+		return enc.Encode(msg)
+	}})
+
+	log.Printf("listening for callbacks on %s%s", addr, path)
+	return http.ListenAndServe(addr, mux)
+}
@@ -0,0 +1,92 @@
+// Command wabactl sends WhatsApp messages through Gupshup, lists templates and
+// runs a local webhook listener that prints the decoded callbacks.
+//
+// Usage:
+//
+//	wabactl send [flags]        send a message, see wabactl send -h
+//	wabactl templates [flags]   list the templates of the app
+//	wabactl listen [flags]      print the callbacks posted to a local webhook
+//
+// The api key, source number, app name and app id are read from
+// GUPSHUP_APIKEY, GUPSHUP_SOURCE, GUPSHUP_APP and GUPSHUP_APP_ID when not
+// given as flags.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	wabaapi "github.com/panitaxx/gupshup-wabaapi"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "send":
+		err = send(os.Args[2:])
+	case "templates":
+		err = templates(os.Args[2:])
+	case "listen":
+		err = listen(os.Args[2:])
+	case "-h", "-help", "help":
+		usage()
+		return
+	default:
+		usage()
+		os.Exit(2)
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "wabactl:", err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: wabactl send|templates|listen [flags]")
+}
+
+// config is shared by the commands that call Gupshup
+type config struct {
+	apiKey     string
+	baseURL    string
+	source     string
+	sourceName string
+	appID      string
+}
+
+func (c *config) register(fs *flag.FlagSet) {
+	fs.StringVar(&c.apiKey, "apikey", os.Getenv("GUPSHUP_APIKEY"), "Gupshup api key")
+	fs.StringVar(&c.baseURL, "baseurl", wabaapi.DefaultBaseURL, "Gupshup API base URL")
+	fs.StringVar(&c.source, "source", os.Getenv("GUPSHUP_SOURCE"), "source phone number")
+	fs.StringVar(&c.sourceName, "app", os.Getenv("GUPSHUP_APP"), "Gupshup app name")
+	fs.StringVar(&c.appID, "appid", os.Getenv("GUPSHUP_APP_ID"), "Gupshup app id, used to upload -file to Gupshup")
+}
+
+func (c *config) client() *wabaapi.Client {
+	return &wabaapi.Client{APIKey: c.apiKey, BaseURL: c.baseURL}
+}
+
+func templates(args []string) error {
+	var cfg config
+	fs := flag.NewFlagSet("templates", flag.ExitOnError)
+	cfg.register(fs)
+	_ = fs.Parse(args)
+
+	list, err := cfg.client().Templates(context.Background(), cfg.sourceName)
+	if err != nil {
+		return err
+	}
+
+	for _, t := range list {
+		fmt.Printf("%s\t%s\t%s\t%s\t%s\n", t.ID, t.ElementName, t.LanguageCode, t.Category, t.Status)
+	}
+	return nil
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	gcs "cloud.google.com/go/storage"
+	"github.com/ansel1/merry"
+	wabaapi "github.com/panitaxx/gupshup-wabaapi"
+)
+
+// spec is a message to send. It is read from the -json file and the flags,
+// flags take precedence
+type spec struct {
+	Type        string   `json:"type"`
+	Destination string   `json:"destination"`
+	Text        string   `json:"text"`
+	URL         string   `json:"url"`
+	PreviewURL  string   `json:"previewUrl"`
+	Caption     string   `json:"caption"`
+	Filename    string   `json:"filename"`
+	File        string   `json:"file"`
+	Template    string   `json:"template"`
+	Params      []string `json:"params"`
+
+	List         *wabaapi.ListMessage    `json:"list"`
+	GlobalButton string                  `json:"globalButton"`
+	QuickReply   *wabaapi.QuickReplyText `json:"quickReply"`
+}
+
+func (s *spec) merge(o spec) {
+	for _, f := range []struct{ dst, src *string }{
+		{&s.Type, &o.Type}, {&s.Destination, &o.Destination}, {&s.Text, &o.Text},
+		{&s.URL, &o.URL}, {&s.PreviewURL, &o.PreviewURL}, {&s.Caption, &o.Caption},
+		{&s.Filename, &o.Filename}, {&s.File, &o.File}, {&s.Template, &o.Template},
+	} {
+		if *f.src != "" {
+			*f.dst = *f.src
+		}
+	}
+	if len(o.Params) > 0 {
+		s.Params = o.Params
+	}
+}
+
+type paramsFlag []string
+
+func (p *paramsFlag) String() string     { return strings.Join(*p, ",") }
+func (p *paramsFlag) Set(v string) error { *p = append(*p, v); return nil }
+
+func send(args []string) error {
+	var cfg config
+	var flags spec
+	var jsonPath, region, bucket, prefix, urlHost string
+	var params paramsFlag
+
+	fs := flag.NewFlagSet("send", flag.ExitOnError)
+	cfg.register(fs)
+	fs.StringVar(&jsonPath, "json", "", "JSON file with the message, flags override its fields")
+	fs.StringVar(&flags.Type, "type", "", "text, image, audio, video, document, list, quickreply or template")
+	fs.StringVar(&flags.Destination, "to", "", "destination phone number")
+	fs.StringVar(&region, "region", "", "default region for national destination numbers, e.g. ES")
+	fs.StringVar(&flags.Text, "text", "", "message text")
+	fs.StringVar(&flags.URL, "url", "", "media URL")
+	fs.StringVar(&flags.PreviewURL, "preview", "", "image preview URL")
+	fs.StringVar(&flags.Caption, "caption", "", "media caption")
+	fs.StringVar(&flags.Filename, "filename", "", "document filename")
+	fs.StringVar(&flags.File, "file", "", "local file to upload through the media server instead of -url")
+	fs.StringVar(&flags.Template, "template", "", "template id")
+	fs.Var(&params, "param", "template param, repeat for every param")
+	fs.StringVar(&bucket, "bucket", os.Getenv("WABACTL_BUCKET"), "GCS bucket used as media server for -file, files are uploaded to Gupshup when empty")
+	fs.StringVar(&prefix, "prefix", "", "path prefix in the bucket")
+	fs.StringVar(&urlHost, "urlhost", "", "public host of the bucket files")
+	_ = fs.Parse(args)
+	flags.Params = params
+
+	var s spec
+	if jsonPath != "" {
+		data, err := ioutil.ReadFile(jsonPath)
+		if err != nil {
+			return err
+		}
+		if err := json.Unmarshal(data, &s); err != nil {
+			return merry.Errorf("failed to parse %s: %s", jsonPath, err)
+		}
+	}
+	s.merge(flags)
+
+	ctx := context.Background()
+	om := &wabaapi.OutboundMessage{
+		Channel:       "whatsapp",
+		Destination:   s.Destination,
+		DefaultRegion: region,
+		Source:        cfg.source,
+		SourceName:    cfg.sourceName,
+	}
+
+	var media *wabaapi.MediaServerMedia
+	if s.File != "" {
+		var server wabaapi.MediaServer = &wabaapi.GupshupMediaServer{Client: cfg.client(), AppID: cfg.appID}
+		if bucket == "" && cfg.appID == "" {
+			return merry.New("-file needs -appid or -bucket")
+		}
+		if bucket != "" {
+			gc, err := gcs.NewClient(ctx)
+			if err != nil {
+				return merry.Wrap(err)
+			}
+			defer gc.Close()
+			server = &wabaapi.GCSMediaServer{Client: gc, Bucket: bucket, PathPrefix: prefix, URLHost: urlHost}
+		}
+
+		f, err := os.Open(s.File)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		media = &wabaapi.MediaServerMedia{
+			Server:      server,
+			Reader:      f,
+			ContentType: mime.TypeByExtension(filepath.Ext(s.File)),
+		}
+		if s.Filename == "" {
+			s.Filename = filepath.Base(s.File)
+		}
+	}
+
+	values, err := build(ctx, om, s, media)
+	if err != nil {
+		return err
+	}
+
+	id, err := cfg.client().Send(ctx, values)
+	if err != nil {
+		return err
+	}
+	fmt.Println(id)
+	return nil
+}
+
+func build(ctx context.Context, om *wabaapi.OutboundMessage, s spec, media *wabaapi.MediaServerMedia) (url.Values, error) {
+	switch s.Type {
+	case "text", "":
+		return om.Text(s.Text)
+	case "image":
+		if media != nil {
+			return om.ImageMSAutoPreview(ctx, *media)
+		}
+		if s.PreviewURL == "" {
+			s.PreviewURL = s.URL
+		}
+		return om.Image(s.URL, s.PreviewURL)
+	case "audio":
+		if media != nil {
+			return om.AudioMS(ctx, *media)
+		}
+		return om.Audio(s.URL)
+	case "video":
+		if media != nil {
+			return om.VideoMS(ctx, *media, s.Caption)
+		}
+		return om.Video(s.URL, s.Caption)
+	case "document":
+		if media != nil {
+			return om.DocumentMS(ctx, *media, s.Filename)
+		}
+		return om.Document(s.URL, s.Filename)
+	case "list":
+		if s.List == nil {
+			return nil, merry.New("list messages need a list in the -json file")
+		}
+		if s.GlobalButton != "" {
+			s.List.GlobalButton = s.GlobalButton
+		}
+		return om.ListMessage(*s.List)
+	case "quickreply":
+		if s.QuickReply == nil {
+			return nil, merry.New("quick reply messages need a quickReply in the -json file")
+		}
+		return om.QuickReplyText(*s.QuickReply)
+	case "template":
+		return om.Template(s.Template, s.Params)
+	}
+	return nil, merry.Errorf("unknown message type %s", s.Type)
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	wabaapi "github.com/panitaxx/gupshup-wabaapi"
+	"github.com/panitaxx/gupshup-wabaapi/gupshuptest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSendListFromFile(t *testing.T) {
+	srv := gupshuptest.NewServer("demo")
+	defer srv.Close()
+
+	path := filepath.Join(t.TempDir(), "list.json")
+	require.NoError(t, ioutil.WriteFile(path, []byte(`{"type":"list","destination":"34600000001","list":{
+		"title":"Menu","body":"Pick a dish","globalButtons":[{"type":"text","title":"Dishes"}],
+		"items":[{"title":"Mains","options":[{"type":"text","title":"Paella","description":"With seafood"}]}]}}`), 0o600))
+
+	require.NoError(t, send([]string{"-json", path, "-apikey", "gupshuptest", "-baseurl", srv.BaseURL(),
+		"-source", "15555555555", "-app", "demo"}))
+
+	msgs := srv.Messages()
+	require.Len(t, msgs, 1)
+	list, ok := msgs[0].Payload.(wabaapi.ListMessage)
+	require.True(t, ok)
+	assert.Equal(t, "Dishes", list.GlobalButton)
+	assert.Equal(t, "Paella", list.Items[0].Options[0].Title)
+}
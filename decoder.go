@@ -0,0 +1,128 @@
+package wabaapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/ansel1/merry"
+)
+
+// ErrUnknownType is wrapped by UnknownTypeError
+var ErrUnknownType = merry.New("unknown inbound type")
+
+// UnknownTypeError is returned by a strict InboundDecoder for callback or
+// message types it has no decoder for
+type UnknownTypeError struct {
+	// Event is the callback type, message for inbound messages
+	Event string
+	// Type is the message type of unknown messages, empty for unknown events
+	Type string
+}
+
+func (e *UnknownTypeError) Error() string {
+	if e.Type != "" {
+		return fmt.Sprintf("%s: %s %s", ErrUnknownType, e.Event, e.Type)
+	}
+	return fmt.Sprintf("%s: %s", ErrUnknownType, e.Event)
+}
+
+func (e *UnknownTypeError) Unwrap() error {
+	return ErrUnknownType
+}
+
+// UnknownPayload keeps the raw JSON of callbacks and messages of types the
+// decoder doesn't know, so they are not lost and encode back unchanged
+type UnknownPayload struct {
+	Type string
+	Raw  json.RawMessage
+}
+
+func (UnknownPayload) inboundEvent()   {}
+func (UnknownPayload) inboundContent() {}
+
+// MarshalJSON writes the raw payload
+func (p UnknownPayload) MarshalJSON() ([]byte, error) {
+	if len(p.Raw) == 0 {
+		return []byte("null"), nil
+	}
+	return p.Raw, nil
+}
+
+// InboundExtension is embedded by the payload types of registered decoders
+// to implement InboundEvent and InboundContent
+type InboundExtension struct{}
+
+func (InboundExtension) inboundEvent()   {}
+func (InboundExtension) inboundContent() {}
+
+// EventDecoder decodes the payload of a callback type
+type EventDecoder func(payload json.RawMessage) (InboundEvent, error)
+
+// ContentDecoder decodes the payload of a message type
+type ContentDecoder func(payload json.RawMessage) (InboundContent, error)
+
+// InboundDecoder decodes callbacks. Registered decoders take precedence over
+// the built-in types, types without decoder are decoded as UnknownPayload or,
+// when Strict is set, fail with an UnknownTypeError.
+// The zero value is ready to use
+type InboundDecoder struct {
+	Strict bool
+
+	mu       sync.RWMutex
+	events   map[string]EventDecoder
+	contents map[string]ContentDecoder
+}
+
+// DefaultInboundDecoder is used by json.Unmarshal and by WebhookHandler when
+// it has no Decoder
+var DefaultInboundDecoder = &InboundDecoder{}
+
+// RegisterEventDecoder registers fn for callbacks of typ in DefaultInboundDecoder
+func RegisterEventDecoder(typ string, fn EventDecoder) {
+	DefaultInboundDecoder.RegisterEvent(typ, fn)
+}
+
+// RegisterContentDecoder registers fn for messages of typ in DefaultInboundDecoder
+func RegisterContentDecoder(typ string, fn ContentDecoder) {
+	DefaultInboundDecoder.RegisterContent(typ, fn)
+}
+
+// RegisterEvent registers fn for callbacks of typ, like account-event
+func (d *InboundDecoder) RegisterEvent(typ string, fn EventDecoder) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.events == nil {
+		d.events = map[string]EventDecoder{}
+	}
+	d.events[typ] = fn
+}
+
+// RegisterContent registers fn for messages of typ, like reaction
+func (d *InboundDecoder) RegisterContent(typ string, fn ContentDecoder) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.contents == nil {
+		d.contents = map[string]ContentDecoder{}
+	}
+	d.contents[typ] = fn
+}
+
+func (d *InboundDecoder) eventDecoder(typ string) EventDecoder {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.events[typ]
+}
+
+func (d *InboundDecoder) contentDecoder(typ string) ContentDecoder {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.contents[typ]
+}
+
+// Decode decodes a callback body
+func (d *InboundDecoder) Decode(data []byte) (InboundMessage, error) {
+	var m InboundMessage
+	err := d.decode(data, &m)
+	return m, err
+}
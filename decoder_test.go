@@ -0,0 +1,79 @@
+package wabaapi
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const pollCallback = `{"app":"app","timestamp":1580227766370,"version":2,"type":"message","payload":{"id":"1","source":"34600000001","type":"poll","payload":{"question":"?","options":["a","b"]},"sender":{"phone":"34600000001","name":"","country_code":"","dial_code":""}}}`
+
+type testPoll struct {
+	InboundExtension
+	Question string `json:"question"`
+}
+
+func TestUnknownPayload(t *testing.T) {
+	var m InboundMessage
+	require.NoError(t, json.Unmarshal([]byte(pollCallback), &m))
+	msg, ok := m.AsMessage()
+	require.True(t, ok)
+	assert.Equal(t, UnknownPayload{Type: "poll", Raw: json.RawMessage(`{"question":"?","options":["a","b"]}`)}, msg.Payload)
+
+	data, err := json.Marshal(m)
+	require.NoError(t, err)
+	assert.JSONEq(t, pollCallback, string(data))
+
+	require.NoError(t, json.Unmarshal([]byte(`{"app":"app","timestamp":1580227766370,"type":"new-event","payload":{"a":1}}`), &m))
+	assert.Equal(t, UnknownPayload{Type: "new-event", Raw: json.RawMessage(`{"a":1}`)}, m.Payload)
+}
+
+func TestStrictDecoder(t *testing.T) {
+	dec := &InboundDecoder{Strict: true}
+	_, err := dec.Decode([]byte(pollCallback))
+	var ute *UnknownTypeError
+	require.True(t, errors.As(err, &ute))
+	assert.Equal(t, "message", ute.Event)
+	assert.Equal(t, "poll", ute.Type)
+	assert.True(t, errors.Is(err, ErrUnknownType))
+
+	_, err = dec.Decode([]byte(`{"app":"app","timestamp":1580227766370,"type":"new-event","payload":{}}`))
+	assert.True(t, errors.Is(err, ErrUnknownType))
+}
+
+func TestRegisteredDecoder(t *testing.T) {
+	dec := &InboundDecoder{Strict: true}
+	dec.RegisterContent("poll", func(payload json.RawMessage) (InboundContent, error) {
+		var r testPoll
+		err := json.Unmarshal(payload, &r)
+		return r, err
+	})
+
+	m, err := dec.Decode([]byte(pollCallback))
+	require.NoError(t, err)
+	msg, _ := m.AsMessage()
+	assert.Equal(t, testPoll{Question: "?"}, msg.Payload)
+
+	var visited InboundContent
+	v := &extensionVisitor{visit: func(c InboundContent) { visited = c }}
+	require.NoError(t, m.Accept(v))
+	assert.Equal(t, msg.Payload, visited)
+
+	// the default decoder is not affected
+	require.NoError(t, json.Unmarshal([]byte(pollCallback), &m))
+	msg, _ = m.AsMessage()
+	assert.IsType(t, UnknownPayload{}, msg.Payload)
+}
+
+type extensionVisitor struct {
+	NopInboundVisitor
+	visit func(InboundContent)
+}
+
+func (v *extensionVisitor) VisitExtensionContent(msg InboundMessagePayload, c InboundContent) error {
+	v.visit(c)
+	return nil
+}
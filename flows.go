@@ -0,0 +1,385 @@
+package wabaapi
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/ansel1/merry"
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+)
+
+// Flow actions
+const (
+	FlowNavigate     = "navigate"
+	FlowDataExchange = "data_exchange"
+)
+
+// Flow creates a message that opens a WhatsApp Flow
+func (om *OutboundMessage) Flow(fm FlowMessage) (url.Values, error) {
+	return om.Build(fm)
+}
+
+// FlowMessage opens the flow FlowID when the user taps the FlowCTA button.
+// With FlowNavigate, the default, the flow starts at Screen with Data.
+// With FlowDataExchange the first screen is requested to the data exchange
+// endpoint, see FlowHandler
+type FlowMessage struct {
+	MsgID  string
+	Header *InteractiveHeader
+	Body   string
+	Footer string
+
+	FlowID string
+	// FlowCTA is the button text
+	FlowCTA string
+	// FlowToken identifies the flow session, it is sent back in the
+	// data exchange requests and in the InboundFlowReply
+	FlowToken  string
+	FlowAction string
+	Screen     string
+	Data       map[string]interface{}
+}
+
+func (fm FlowMessage) MessageType() string { return "flow" }
+func (fm FlowMessage) Encode() url.Values  { return encodeMessage(fm) }
+
+func (fm FlowMessage) Validate() error {
+	navigate := fm.FlowAction == "" || fm.FlowAction == FlowNavigate
+	return validation.ValidateStruct(&fm,
+		validation.Field(&fm.Header),
+		validation.Field(&fm.Body, validation.Required, validation.RuneLength(1, 1024)),
+		validation.Field(&fm.Footer, validation.RuneLength(0, 60)),
+		validation.Field(&fm.FlowID, validation.Required),
+		validation.Field(&fm.FlowCTA, validation.Required, validation.RuneLength(1, 30)),
+		validation.Field(&fm.FlowAction, validation.In(FlowNavigate, FlowDataExchange)),
+		validation.Field(&fm.Screen, validation.When(navigate, validation.Required).Else(validation.Empty)),
+		validation.Field(&fm.Data, validation.When(!navigate, validation.Empty)),
+	)
+}
+
+type flowActionPayload struct {
+	Screen string                 `json:"screen,omitempty"`
+	Data   map[string]interface{} `json:"data,omitempty"`
+}
+
+type flowMessage struct {
+	Type              string             `json:"type"`
+	MsgID             string             `json:"msgid,omitempty"`
+	Header            *InteractiveHeader `json:"header,omitempty"`
+	Body              string             `json:"body"`
+	Footer            string             `json:"footer,omitempty"`
+	FlowID            string             `json:"flowId"`
+	FlowCTA           string             `json:"flowCta"`
+	FlowToken         string             `json:"flowToken,omitempty"`
+	FlowAction        string             `json:"flowAction"`
+	FlowActionPayload *flowActionPayload `json:"flowActionPayload,omitempty"`
+}
+
+func (fm FlowMessage) MarshalJSON() ([]byte, error) {
+	tmp := flowMessage{
+		Type:       fm.MessageType(),
+		MsgID:      fm.MsgID,
+		Header:     fm.Header,
+		Body:       fm.Body,
+		Footer:     fm.Footer,
+		FlowID:     fm.FlowID,
+		FlowCTA:    fm.FlowCTA,
+		FlowToken:  fm.FlowToken,
+		FlowAction: fm.FlowAction,
+	}
+	if tmp.FlowAction == "" {
+		tmp.FlowAction = FlowNavigate
+	}
+	if fm.Screen != "" || fm.Data != nil {
+		tmp.FlowActionPayload = &flowActionPayload{Screen: fm.Screen, Data: fm.Data}
+	}
+	return json.Marshal(tmp)
+}
+
+func (fm *FlowMessage) UnmarshalJSON(data []byte) error {
+	var tmp flowMessage
+	if err := json.Unmarshal(data, &tmp); err != nil {
+		return err
+	}
+	*fm = FlowMessage{
+		MsgID:      tmp.MsgID,
+		Header:     tmp.Header,
+		Body:       tmp.Body,
+		Footer:     tmp.Footer,
+		FlowID:     tmp.FlowID,
+		FlowCTA:    tmp.FlowCTA,
+		FlowToken:  tmp.FlowToken,
+		FlowAction: tmp.FlowAction,
+	}
+	if tmp.FlowActionPayload != nil {
+		fm.Screen = tmp.FlowActionPayload.Screen
+		fm.Data = tmp.FlowActionPayload.Data
+	}
+	return nil
+}
+
+// InboundFlowReply is the nfm_reply message sent when the user completes a flow
+type InboundFlowReply struct {
+	Name string
+	Body string
+	// ResponseJSON is the data of the flow's complete action, with the flow_token
+	ResponseJSON json.RawMessage
+	// FlowToken is the token of the FlowMessage, from ResponseJSON
+	FlowToken string
+}
+
+type inboundFlowReply struct {
+	Name         string `json:"name"`
+	Body         string `json:"body"`
+	ResponseJSON string `json:"response_json"`
+}
+
+func (fr *InboundFlowReply) UnmarshalJSON(data []byte) error {
+	var tmp inboundFlowReply
+	if err := json.Unmarshal(data, &tmp); err != nil {
+		return err
+	}
+	*fr = InboundFlowReply{Name: tmp.Name, Body: tmp.Body}
+	if tmp.ResponseJSON == "" {
+		return nil
+	}
+
+	fr.ResponseJSON = json.RawMessage(tmp.ResponseJSON)
+	var token struct {
+		FlowToken string `json:"flow_token"`
+	}
+	if err := json.Unmarshal(fr.ResponseJSON, &token); err != nil {
+		return merry.Errorf("failed to parse response_json: %s", err)
+	}
+	fr.FlowToken = token.FlowToken
+	return nil
+}
+
+func (fr InboundFlowReply) MarshalJSON() ([]byte, error) {
+	return json.Marshal(inboundFlowReply{Name: fr.Name, Body: fr.Body, ResponseJSON: string(fr.ResponseJSON)})
+}
+
+// Decode unmarshals the response into v
+func (fr InboundFlowReply) Decode(v interface{}) error {
+	return json.Unmarshal(fr.ResponseJSON, v)
+}
+
+// FlowRequest is a decrypted request to the data exchange endpoint
+type FlowRequest struct {
+	Version string `json:"version"`
+	// Action is INIT, BACK, data_exchange or ping. ping is answered by FlowHandler
+	Action    string                 `json:"action"`
+	Screen    string                 `json:"screen,omitempty"`
+	Data      map[string]interface{} `json:"data,omitempty"`
+	FlowToken string                 `json:"flow_token,omitempty"`
+}
+
+// FlowResponse is the next screen of the flow and its data. To complete the
+// flow return the SUCCESS screen with the extension_message_response params
+type FlowResponse struct {
+	Screen string                 `json:"screen,omitempty"`
+	Data   map[string]interface{} `json:"data"`
+}
+
+// FlowEnvelope is the encrypted body of data exchange requests
+type FlowEnvelope struct {
+	EncryptedFlowData string `json:"encrypted_flow_data"`
+	EncryptedAESKey   string `json:"encrypted_aes_key"`
+	InitialVector     string `json:"initial_vector"`
+}
+
+// FlowHandler is an http.Handler for the data exchange endpoint of flows.
+// Requests are decrypted with PrivateKey, the key whose public part is
+// uploaded to WhatsApp, passed to Handle and the response is encrypted back.
+// When AppSecret is set the X-Hub-Signature-256 header is verified
+type FlowHandler struct {
+	PrivateKey *rsa.PrivateKey
+	AppSecret  string
+	Handle     func(ctx context.Context, req FlowRequest) (FlowResponse, error)
+}
+
+// ErrFlowDecrypt is returned when a request cannot be decrypted. FlowHandler
+// responds with 421 so the client refreshes the public key
+var ErrFlowDecrypt = merry.New("failed to decrypt flow request").WithHTTPCode(421)
+
+func (fh *FlowHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if fh.AppSecret != "" && !validSignature(fh.AppSecret, body, r.Header.Get("X-Hub-Signature-256")) {
+		http.Error(w, "invalid signature", http.StatusUnauthorized)
+		return
+	}
+
+	var env FlowEnvelope
+	if err := json.Unmarshal(body, &env); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	req, aesKey, iv, err := DecryptFlowRequest(fh.PrivateKey, env)
+	if err != nil {
+		http.Error(w, err.Error(), merry.HTTPCode(err))
+		return
+	}
+
+	var resp FlowResponse
+	if req.Action == "ping" {
+		resp.Data = map[string]interface{}{"status": "active"}
+	} else if fh.Handle != nil {
+		if resp, err = fh.Handle(r.Context(), req); err != nil {
+			http.Error(w, err.Error(), merry.HTTPCode(err))
+			return
+		}
+	}
+
+	out, err := EncryptFlowResponse(aesKey, iv, resp)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain")
+	_, _ = w.Write([]byte(out))
+}
+
+func validSignature(secret string, body []byte, header string) bool {
+	sig, err := hex.DecodeString(strings.TrimPrefix(header, "sha256="))
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(sig, mac.Sum(nil))
+}
+
+// DecryptFlowRequest decrypts a data exchange request. The AES key and IV are
+// returned to encrypt the response with EncryptFlowResponse
+func DecryptFlowRequest(key *rsa.PrivateKey, env FlowEnvelope) (req FlowRequest, aesKey []byte, iv []byte, err error) {
+	if key == nil {
+		return req, nil, nil, ErrFlowDecrypt.Append("no private key")
+	}
+	encKey, err1 := base64.StdEncoding.DecodeString(env.EncryptedAESKey)
+	data, err2 := base64.StdEncoding.DecodeString(env.EncryptedFlowData)
+	iv, err3 := base64.StdEncoding.DecodeString(env.InitialVector)
+	if err1 != nil || err2 != nil || err3 != nil {
+		return req, nil, nil, ErrFlowDecrypt.Append("invalid base64")
+	}
+
+	aesKey, err = rsa.DecryptOAEP(sha256.New(), rand.Reader, key, encKey, nil)
+	if err != nil {
+		return req, nil, nil, ErrFlowDecrypt.Append(err.Error())
+	}
+	gcm, err := newFlowGCM(aesKey, len(iv))
+	if err != nil {
+		return req, nil, nil, ErrFlowDecrypt.Append(err.Error())
+	}
+	plain, err := gcm.Open(nil, iv, data, nil)
+	if err != nil {
+		return req, nil, nil, ErrFlowDecrypt.Append(err.Error())
+	}
+
+	if err := json.Unmarshal(plain, &req); err != nil {
+		return req, nil, nil, merry.Errorf("failed to parse flow request: %s", err).WithHTTPCode(http.StatusBadRequest)
+	}
+	return req, aesKey, iv, nil
+}
+
+// EncryptFlowResponse encrypts resp with the request's AES key and the flipped IV,
+// and returns it base64 encoded as the response body
+func EncryptFlowResponse(aesKey []byte, iv []byte, resp FlowResponse) (string, error) {
+	plain, err := json.Marshal(resp)
+	if err != nil {
+		return "", err
+	}
+	gcm, err := newFlowGCM(aesKey, len(iv))
+	if err != nil {
+		return "", merry.Wrap(err)
+	}
+	return base64.StdEncoding.EncodeToString(gcm.Seal(nil, flipIV(iv), plain, nil)), nil
+}
+
+// EncryptFlowRequest encrypts req as WhatsApp does, for tests and local tools.
+// The AES key and IV are returned to decrypt the response with DecryptFlowResponse
+func EncryptFlowRequest(pub *rsa.PublicKey, req FlowRequest) (env FlowEnvelope, aesKey []byte, iv []byte, err error) {
+	aesKey = make([]byte, 16)
+	iv = make([]byte, 16)
+	if _, err = rand.Read(aesKey); err != nil {
+		return env, nil, nil, merry.Wrap(err)
+	}
+	if _, err = rand.Read(iv); err != nil {
+		return env, nil, nil, merry.Wrap(err)
+	}
+
+	plain, err := json.Marshal(req)
+	if err != nil {
+		return env, nil, nil, err
+	}
+	encKey, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, pub, aesKey, nil)
+	if err != nil {
+		return env, nil, nil, merry.Wrap(err)
+	}
+	gcm, err := newFlowGCM(aesKey, len(iv))
+	if err != nil {
+		return env, nil, nil, merry.Wrap(err)
+	}
+
+	env = FlowEnvelope{
+		EncryptedFlowData: base64.StdEncoding.EncodeToString(gcm.Seal(nil, iv, plain, nil)),
+		EncryptedAESKey:   base64.StdEncoding.EncodeToString(encKey),
+		InitialVector:     base64.StdEncoding.EncodeToString(iv),
+	}
+	return env, aesKey, iv, nil
+}
+
+// DecryptFlowResponse decrypts a response body of FlowHandler
+func DecryptFlowResponse(aesKey []byte, iv []byte, body string) (FlowResponse, error) {
+	var resp FlowResponse
+	data, err := base64.StdEncoding.DecodeString(body)
+	if err != nil {
+		return resp, merry.Wrap(err)
+	}
+	gcm, err := newFlowGCM(aesKey, len(iv))
+	if err != nil {
+		return resp, merry.Wrap(err)
+	}
+	plain, err := gcm.Open(nil, flipIV(iv), data, nil)
+	if err != nil {
+		return resp, merry.Wrap(err)
+	}
+	err = json.Unmarshal(plain, &resp)
+	return resp, err
+}
+
+func newFlowGCM(key []byte, ivSize int) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCMWithNonceSize(block, ivSize)
+}
+
+func flipIV(iv []byte) []byte {
+	flipped := make([]byte, len(iv))
+	for i, b := range iv {
+		flipped[i] = ^b
+	}
+	return flipped
+}
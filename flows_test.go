@@ -0,0 +1,126 @@
+package wabaapi
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInboundFlowReply(t *testing.T) {
+	msg, data := decodeFixture(t, "nfm_reply.json")
+	reply, ok := msg.AsFlowReply()
+	require.True(t, ok)
+	assert.Equal(t, "flow", reply.Name)
+	assert.Equal(t, "tok-1", reply.FlowToken)
+
+	var form struct {
+		Department string `json:"department"`
+		Date       string `json:"date"`
+	}
+	require.NoError(t, reply.Decode(&form))
+	assert.Equal(t, "shopping", form.Department)
+	assert.Equal(t, "2024-01-20", form.Date)
+	assertRoundTrip(t, data)
+}
+
+func TestFlowMessageValidate(t *testing.T) {
+	fm := FlowMessage{Body: "book", FlowID: "1234", FlowCTA: "Book", Screen: "APPOINTMENT"}
+	assert.NoError(t, fm.Validate())
+
+	fm.Screen = ""
+	assert.Error(t, fm.Validate())
+
+	fm.FlowAction = FlowDataExchange
+	assert.NoError(t, fm.Validate())
+
+	fm.Screen = "APPOINTMENT"
+	assert.Error(t, fm.Validate())
+}
+
+func readFlowKey(t *testing.T) *rsa.PrivateKey {
+	data, err := ioutil.ReadFile("testdata/flow_key.pem")
+	require.NoError(t, err)
+	block, _ := pem.Decode(data)
+	key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+	require.NoError(t, err)
+	return key
+}
+
+func TestFlowHandlerRecorded(t *testing.T) {
+	key := readFlowKey(t)
+	body, err := ioutil.ReadFile("testdata/flow_request.json")
+	require.NoError(t, err)
+
+	var got FlowRequest
+	fh := &FlowHandler{PrivateKey: key, AppSecret: "secret", Handle: func(ctx context.Context, req FlowRequest) (FlowResponse, error) {
+		got = req
+		return FlowResponse{Screen: "DETAILS", Data: map[string]interface{}{"slots": []string{"10:00", "11:00"}}}, nil
+	}}
+
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write(body)
+	req := httptest.NewRequest(http.MethodPost, "/flows", bytes.NewReader(body))
+	req.Header.Set("X-Hub-Signature-256", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	rec := httptest.NewRecorder()
+	fh.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	assert.Equal(t, "data_exchange", got.Action)
+	assert.Equal(t, "APPOINTMENT", got.Screen)
+	assert.Equal(t, "tok-1", got.FlowToken)
+	assert.Equal(t, map[string]interface{}{"department": "shopping"}, got.Data)
+
+	var env FlowEnvelope
+	require.NoError(t, json.Unmarshal(body, &env))
+	encKey, _ := base64.StdEncoding.DecodeString(env.EncryptedAESKey)
+	aesKey, err := rsa.DecryptOAEP(sha256.New(), rand.Reader, key, encKey, nil)
+	require.NoError(t, err)
+	iv, _ := base64.StdEncoding.DecodeString(env.InitialVector)
+
+	resp, err := DecryptFlowResponse(aesKey, iv, rec.Body.String())
+	require.NoError(t, err)
+	assert.Equal(t, "DETAILS", resp.Screen)
+	assert.Equal(t, []interface{}{"10:00", "11:00"}, resp.Data["slots"])
+
+	req = httptest.NewRequest(http.MethodPost, "/flows", bytes.NewReader(body))
+	req.Header.Set("X-Hub-Signature-256", "sha256=00")
+	rec = httptest.NewRecorder()
+	fh.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+}
+
+func TestFlowHandlerPing(t *testing.T) {
+	key := readFlowKey(t)
+	env, aesKey, iv, err := EncryptFlowRequest(&key.PublicKey, FlowRequest{Version: "3.0", Action: "ping"})
+	require.NoError(t, err)
+	body, _ := json.Marshal(env)
+
+	fh := &FlowHandler{PrivateKey: key}
+	rec := httptest.NewRecorder()
+	fh.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/flows", bytes.NewReader(body)))
+	require.Equal(t, http.StatusOK, rec.Code)
+	resp, err := DecryptFlowResponse(aesKey, iv, rec.Body.String())
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"status": "active"}, resp.Data)
+
+	other, _ := rsa.GenerateKey(rand.Reader, 1024)
+	fh.PrivateKey = other
+	rec = httptest.NewRecorder()
+	fh.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/flows", bytes.NewReader(body)))
+	assert.Equal(t, 421, rec.Code)
+}
@@ -6,5 +6,8 @@ require (
 	cloud.google.com/go/storage v1.18.2
 	github.com/ansel1/merry v1.6.1
 	github.com/go-ozzo/ozzo-validation/v4 v4.3.0
-	github.com/stretchr/testify v1.7.0
+	github.com/nyaruka/phonenumbers v1.1.0
+	github.com/stretchr/testify v1.7.1
+	golang.org/x/net v0.0.0-20210503060351-7fd8e65b6420
+	google.golang.org/api v0.58.0
 )
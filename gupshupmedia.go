@@ -0,0 +1,172 @@
+package wabaapi
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+
+	"github.com/ansel1/merry"
+)
+
+func (c *Client) mediaURL(appID string) string {
+	return c.rootURL() + "/wa/" + url.PathEscape(appID) + "/wa/media/"
+}
+
+// UploadMedia uploads a file to Gupshup's media store of the app with id appID
+// and returns its media ID, to send it with the ID of ImageMessage,
+// AudioMessage, VideoMessage or DocumentMessage. appID is the app id of the
+// partner API, see PartnerApp, not the app name. The file is not validated,
+// see MediaServerMedia.Validate
+func (c *Client) UploadMedia(ctx context.Context, appID string, r io.Reader, contentType string) (string, error) {
+	if contentType == "" {
+		return "", merry.New("content type not specified")
+	}
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	if err := mw.WriteField("file_type", contentType); err != nil {
+		return "", merry.Wrap(err)
+	}
+	header := textproto.MIMEHeader{}
+	header.Set("Content-Disposition", `form-data; name="file"; filename="media"`)
+	header.Set("Content-Type", contentType)
+	part, err := mw.CreatePart(header)
+	if err != nil {
+		return "", merry.Wrap(err)
+	}
+	if _, err := io.Copy(part, r); err != nil {
+		return "", merry.Wrap(err)
+	}
+	if err := mw.Close(); err != nil {
+		return "", merry.Wrap(err)
+	}
+
+	var resp struct {
+		Status  string `json:"status"`
+		MediaID string `json:"mediaId"`
+		Message string `json:"message"`
+	}
+	if _, err := c.doURL(ctx, http.MethodPost, c.mediaURL(appID), &body, mw.FormDataContentType(), &resp); err != nil {
+		return "", err
+	}
+	if resp.MediaID == "" {
+		return "", merry.Errorf("media not uploaded: %s %s", resp.Status, resp.Message)
+	}
+	return resp.MediaID, nil
+}
+
+// DownloadMedia returns a media uploaded to the media store of the app with id
+// appID and its content type
+func (c *Client) DownloadMedia(ctx context.Context, appID string, id string) (io.ReadCloser, string, error) {
+	if c.APIKey == "" {
+		return nil, "", merry.New("client api key not configured")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.mediaURL(appID)+url.PathEscape(id), nil)
+	if err != nil {
+		return nil, "", merry.Wrap(err)
+	}
+	req.Header.Set("apikey", c.APIKey)
+
+	resp, err := c.httpClient().Do(req)
+	if err != nil {
+		return nil, "", merry.Wrap(err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		defer resp.Body.Close()
+		data, _ := ioutil.ReadAll(resp.Body)
+		return nil, "", responseError(resp.StatusCode, data)
+	}
+	return resp.Body, resp.Header.Get("Content-Type"), nil
+}
+
+// MediaIDServer is a MediaServer whose PutFile returns media IDs of Gupshup's
+// media store instead of URLs. The media builders taking a MediaServerMedia
+// send the media of these servers by ID, and by URL for any other server
+type MediaIDServer interface {
+	MediaServer
+	// MediaIDs reports whether PutFile returns media IDs
+	MediaIDs() bool
+}
+
+var _ MediaIDServer = (*GupshupMediaServer)(nil)
+
+// GupshupMediaServer is a MediaServer storing files in Gupshup's media store
+// of the app with id AppID, so no public bucket is needed. PutFile returns
+// media IDs instead of URLs. Operations are reported to the Client Observer
+type GupshupMediaServer struct {
+	Client *Client
+	// AppID is the app id of the partner API, not the app name
+	AppID string
+}
+
+// MediaIDs returns true, PutFile returns media IDs
+func (ms *GupshupMediaServer) MediaIDs() bool { return true }
+
+func (ms *GupshupMediaServer) observe(ctx context.Context, op string) (context.Context, func(int64, error)) {
+	if ms.Client == nil || ms.Client.Observer == nil {
+		return ctx, func(int64, error) {}
+	}
+	return ms.Client.Observer.MediaStart(ctx, op)
+}
+
+// GetFile downloads the media with id requri
+func (ms *GupshupMediaServer) GetFile(ctx context.Context, requri string) (io.ReadCloser, string, error) {
+	if ms.Client == nil || ms.AppID == "" {
+		return nil, "", merry.New("GupshupMediaServer not configured")
+	}
+
+	ctx, done := ms.observe(ctx, "get")
+	rc, ctype, err := ms.Client.DownloadMedia(ctx, ms.AppID, requri)
+	if err != nil {
+		done(0, err)
+		return nil, "", err
+	}
+	return &observedReadCloser{countingReader: countingReader{Reader: rc}, closer: rc, done: done}, ctype, nil
+}
+
+// PutFile uploads a file and returns its media ID
+func (ms *GupshupMediaServer) PutFile(ctx context.Context, r io.Reader, contentType string) (string, error) {
+	if ms.Client == nil || ms.AppID == "" {
+		return "", merry.New("GupshupMediaServer not configured")
+	}
+
+	ctx, done := ms.observe(ctx, "put")
+	cr := &countingReader{Reader: r}
+	id, err := ms.Client.UploadMedia(ctx, ms.AppID, cr, contentType)
+	done(cr.n, err)
+	return id, err
+}
+
+// PutFileWithExt uploads a file with the content type of ext and returns its media ID
+func (ms *GupshupMediaServer) PutFileWithExt(ctx context.Context, r io.Reader, ext string) (string, error) {
+	if ext == "" {
+		return "", merry.New("extension not specified")
+	}
+	ctype := mime.TypeByExtension(ext)
+	if ctype == "" {
+		return "", merry.New("content type not found")
+	}
+	return ms.PutFile(ctx, r, ctype)
+}
+
+// returnsMediaIDs reports whether server is a MediaIDServer returning media IDs
+func returnsMediaIDs(server MediaServer) bool {
+	ids, ok := server.(MediaIDServer)
+	return ok && ids.MediaIDs()
+}
+
+// mediaRef returns the reference returned by the PutFile of server as a URL
+// or, for MediaIDServer servers, as an ID
+func mediaRef(server MediaServer, ref string) (mediaURL string, id string) {
+	if returnsMediaIDs(server) {
+		return "", ref
+	}
+	return ref, ""
+}
@@ -0,0 +1,58 @@
+package gupshuptest
+
+import (
+	"encoding/json"
+	"net/url"
+	"time"
+
+	"github.com/ansel1/merry"
+	wabaapi "github.com/panitaxx/gupshup-wabaapi"
+)
+
+// Message is a message received by the fake, with its payload decoded
+type Message struct {
+	ID          string
+	ReceivedAt  time.Time
+	Values      url.Values
+	Channel     string
+	Source      string
+	SourceName  string
+	Destination string
+	// Type is the message type, or "template" for template messages
+	Type string
+	// Payload is the wabaapi.Outbound message, such as wabaapi.TextMessage or
+	// wabaapi.TemplateMessage. Types wabaapi does not build are left as a map
+	Payload interface{}
+}
+
+// Decode decodes the values created by wabaapi.OutboundMessage
+func Decode(values url.Values) (Message, error) {
+	om, out, err := wabaapi.DecodeOutbound(values)
+	msg := Message{
+		Values:      values,
+		Channel:     om.Channel,
+		Source:      om.Source,
+		SourceName:  om.SourceName,
+		Destination: om.Destination,
+	}
+	if msg.Destination == "" {
+		return msg, merry.New("destination not specified")
+	}
+	if err == nil {
+		msg.Type = out.MessageType()
+		msg.Payload = out
+		return msg, nil
+	}
+
+	var fields map[string]interface{}
+	if json.Unmarshal([]byte(values.Get("message")), &fields) != nil || values.Get("template") != "" {
+		return msg, err
+	}
+	typ, _ := fields["type"].(string)
+	if typ == "" {
+		return msg, err
+	}
+	msg.Type = typ
+	msg.Payload = fields
+	return msg, nil
+}
@@ -0,0 +1,135 @@
+package gupshuptest
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/ansel1/merry"
+)
+
+// handleMedia emulates the media upload endpoint, POST /wa/{appId}/wa/media/
+// with a multipart file and file_type, and GET /wa/{appId}/wa/media/{id} to
+// download an uploaded file. appId is the AppID of the fake
+func (s *Server) handleMedia(w http.ResponseWriter, r *http.Request) {
+	if !s.authorized(w, r) {
+		return
+	}
+
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(parts) < 4 || parts[2] != "wa" || parts[3] != "media" {
+		http.NotFound(w, r)
+		return
+	}
+	if parts[1] != s.AppID {
+		writeJSON(w, http.StatusNotFound, map[string]string{"status": "error", "message": "Invalid App Details"})
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPost:
+		f, _, err := r.FormFile("file")
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"status": "error", "message": err.Error()})
+			return
+		}
+		defer f.Close()
+		data, err := ioutil.ReadAll(f)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"status": "error", "message": err.Error()})
+			return
+		}
+
+		id := newID()
+		s.mu.Lock()
+		s.media[id] = File{ContentType: r.FormValue("file_type"), Data: data}
+		s.mu.Unlock()
+		writeJSON(w, http.StatusOK, map[string]string{"status": "success", "mediaId": id})
+	case http.MethodGet:
+		if len(parts) < 5 {
+			http.NotFound(w, r)
+			return
+		}
+		s.serveFile(w, r, parts[4])
+	default:
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"status": "error", "message": "method not allowed"})
+	}
+}
+
+func (s *Server) handleFiles(w http.ResponseWriter, r *http.Request) {
+	s.serveFile(w, r, path.Base(r.URL.Path))
+}
+
+func (s *Server) serveFile(w http.ResponseWriter, r *http.Request, id string) {
+	f, ok := s.File(id)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", f.ContentType)
+	_, _ = w.Write(f.Data)
+}
+
+// File returns an uploaded file by media id or by name for files stored
+// through MediaServer
+func (s *Server) File(id string) (File, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, ok := s.media[id]
+	return f, ok
+}
+
+// MediaServer returns a wabaapi.MediaServer that stores files in the fake and
+// serves them from its /files/ path
+func (s *Server) MediaServer() *MediaServer {
+	return &MediaServer{s: s}
+}
+
+// MediaServer is a wabaapi.MediaServer backed by a Server
+type MediaServer struct {
+	s *Server
+}
+
+// GetFile returns a stored file and its content type
+func (ms *MediaServer) GetFile(ctx context.Context, requri string) (io.ReadCloser, string, error) {
+	f, ok := ms.s.File(path.Base(requri))
+	if !ok {
+		return nil, "", merry.New("file not found").WithHTTPCode(http.StatusNotFound)
+	}
+	return ioutil.NopCloser(bytes.NewReader(f.Data)), f.ContentType, nil
+}
+
+// PutFile stores a file and returns its URL
+func (ms *MediaServer) PutFile(ctx context.Context, r io.Reader, contentType string) (string, error) {
+	if contentType == "" {
+		return "", merry.New("content type not specified")
+	}
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return "", merry.Wrap(err)
+	}
+
+	name := newID()
+	if exts, _ := mime.ExtensionsByType(contentType); len(exts) > 0 {
+		name += exts[0]
+	}
+
+	ms.s.mu.Lock()
+	ms.s.media[name] = File{ContentType: contentType, Data: data}
+	ms.s.mu.Unlock()
+	return ms.s.URL + "/files/" + name, nil
+}
+
+// PutFileWithExt stores a file with the content type of ext and returns its URL
+func (ms *MediaServer) PutFileWithExt(ctx context.Context, r io.Reader, ext string) (string, error) {
+	ctype := mime.TypeByExtension(ext)
+	if ctype == "" {
+		return "", merry.New("content type not found")
+	}
+	return ms.PutFile(ctx, r, ctype)
+}
@@ -0,0 +1,142 @@
+package gupshuptest
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	wabaapi "github.com/panitaxx/gupshup-wabaapi"
+)
+
+// partnerToken is the token returned by the fake partner login
+const partnerToken = "gupshuptest-partner"
+
+// NewPartnerClient returns a wabaapi.PartnerClient that manages the app of the
+// fake, already logged in
+func (s *Server) NewPartnerClient() *wabaapi.PartnerClient {
+	return &wabaapi.PartnerClient{Token: partnerToken, BaseURL: s.URL + "/partner", HTTPClient: s.Server.Client()}
+}
+
+// AppSettings returns the settings of the app changed through the partner API
+func (s *Server) AppSettings() (callbackURL string, subs []wabaapi.Subscription, optinRequired bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	subs = make([]wabaapi.Subscription, len(s.subscriptions))
+	copy(subs, s.subscriptions)
+	return s.callbackURL, subs, s.optinRequired
+}
+
+// RotateAppToken makes the fake reject the app token handed out so far, like
+// an expired token
+func (s *Server) RotateAppToken() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tokenGeneration++
+}
+
+func (s *Server) appToken() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return "sk_" + strings.Replace(s.AppID, "-", "", -1) + "_" + strconv.Itoa(s.tokenGeneration)
+}
+
+// handlePartner emulates the partner API: the login, the app list, the app
+// token and the callback URL, subscription and preference endpoints of the app
+func (s *Server) handlePartner(w http.ResponseWriter, r *http.Request) {
+	endpoint := strings.TrimPrefix(r.URL.Path, "/partner")
+	switch {
+	case endpoint == "/account/login":
+		if r.Method != http.MethodPost || r.FormValue("email") == "" || r.FormValue("password") == "" {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"status": "error", "message": "Invalid credentials"})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"token": partnerToken})
+		return
+	case endpoint == "/account/api/partnerApps":
+		if !s.partnerAuthorized(w, r, partnerToken) {
+			return
+		}
+		apps := []wabaapi.PartnerApp{{ID: s.AppID, Name: s.App, Live: true, Healthy: true}}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"status": "success", "partnerAppsList": apps})
+		return
+	}
+
+	parts := strings.Split(strings.TrimPrefix(endpoint, "/app/"), "/")
+	if !strings.HasPrefix(endpoint, "/app/") || len(parts) < 2 {
+		http.NotFound(w, r)
+		return
+	}
+	if parts[0] != s.AppID {
+		writeJSON(w, http.StatusNotFound, map[string]string{"status": "error", "message": "Invalid App Details"})
+		return
+	}
+	if parts[1] == "token" {
+		if s.partnerAuthorized(w, r, partnerToken) {
+			writeJSON(w, http.StatusOK, map[string]interface{}{"status": "success", "token": map[string]string{"token": s.appToken()}})
+		}
+		return
+	}
+	if !s.partnerAuthorized(w, r, s.appToken()) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch {
+	case parts[1] == "callbackUrl" && r.Method == http.MethodPut:
+		s.callbackURL = r.FormValue("callbackUrl")
+		writeJSON(w, http.StatusOK, map[string]string{"status": "success"})
+	case parts[1] == "appPreference" && r.Method == http.MethodPut:
+		s.optinRequired, _ = strconv.ParseBool(r.FormValue("isOptinRequired"))
+		writeJSON(w, http.StatusOK, map[string]string{"status": "success"})
+	case parts[1] == "subscription" && len(parts) == 2 && r.Method == http.MethodGet:
+		subs := make([]map[string]interface{}, len(s.subscriptions))
+		for i, sub := range s.subscriptions {
+			subs[i] = subscriptionJSON(sub)
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"status": "success", "subscriptions": subs})
+	case parts[1] == "subscription" && len(parts) == 2 && r.Method == http.MethodPost:
+		version, _ := strconv.Atoi(r.FormValue("version"))
+		sub := wabaapi.Subscription{
+			ID:      newID(),
+			Tag:     r.FormValue("tag"),
+			URL:     r.FormValue("url"),
+			Modes:   strings.Split(r.FormValue("modes"), ","),
+			Version: version,
+			Active:  true,
+		}
+		s.subscriptions = append(s.subscriptions, sub)
+		writeJSON(w, http.StatusOK, map[string]interface{}{"status": "success", "subscription": subscriptionJSON(sub)})
+	case parts[1] == "subscription" && len(parts) == 3 && r.Method == http.MethodDelete:
+		for i, sub := range s.subscriptions {
+			if sub.ID == parts[2] {
+				s.subscriptions = append(s.subscriptions[:i], s.subscriptions[i+1:]...)
+				writeJSON(w, http.StatusOK, map[string]string{"status": "success"})
+				return
+			}
+		}
+		writeJSON(w, http.StatusNotFound, map[string]string{"status": "error", "message": "Subscription not found"})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (s *Server) partnerAuthorized(w http.ResponseWriter, r *http.Request, token string) bool {
+	if r.Header.Get("Authorization") != token {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"status": "error", "message": "Authentication Failed"})
+		return false
+	}
+	return true
+}
+
+// subscriptionJSON is sub as Gupshup sends it, with the modes comma separated
+func subscriptionJSON(sub wabaapi.Subscription) map[string]interface{} {
+	return map[string]interface{}{
+		"id":      sub.ID,
+		"tag":     sub.Tag,
+		"url":     sub.URL,
+		"modes":   strings.Join(sub.Modes, ","),
+		"version": sub.Version,
+		"active":  sub.Active,
+	}
+}
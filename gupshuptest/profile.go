@@ -0,0 +1,62 @@
+package gupshuptest
+
+import (
+	"io/ioutil"
+	"net/http"
+)
+
+// handleProfile emulates GET and PUT /app/{app}/business/profile, with the
+// fields form encoded, and PUT /app/{app}/business/profile/photo with a
+// multipart image. The photo is served from the /files/ path
+func (s *Server) handleProfile(w http.ResponseWriter, r *http.Request, parts []string) {
+	switch {
+	case len(parts) == 1 && parts[0] == "profile" && r.Method == http.MethodGet:
+		s.mu.Lock()
+		profile := s.Profile
+		s.mu.Unlock()
+		writeJSON(w, http.StatusOK, map[string]interface{}{"status": "success", "profile": profile})
+	case len(parts) == 1 && parts[0] == "profile" && r.Method == http.MethodPut:
+		if err := r.ParseForm(); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"status": "error", "message": err.Error()})
+			return
+		}
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		for key, field := range map[string]*string{
+			"about":       &s.Profile.About,
+			"description": &s.Profile.Description,
+			"address":     &s.Profile.Address,
+			"email":       &s.Profile.Email,
+			"vertical":    &s.Profile.Vertical,
+		} {
+			if v := r.PostForm.Get(key); v != "" {
+				*field = v
+			}
+		}
+		if websites := r.PostForm["websites"]; len(websites) > 0 {
+			s.Profile.Websites = websites
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"status": "success"})
+	case len(parts) == 2 && parts[0] == "profile" && parts[1] == "photo" && r.Method == http.MethodPut:
+		f, header, err := r.FormFile("image")
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"status": "error", "message": err.Error()})
+			return
+		}
+		defer f.Close()
+		data, err := ioutil.ReadAll(f)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"status": "error", "message": err.Error()})
+			return
+		}
+
+		id := newID()
+		s.mu.Lock()
+		s.media[id] = File{ContentType: header.Header.Get("Content-Type"), Data: data}
+		s.Profile.PhotoURL = s.URL + "/files/" + id
+		s.mu.Unlock()
+		writeJSON(w, http.StatusOK, map[string]string{"status": "success"})
+	default:
+		http.NotFound(w, r)
+	}
+}
@@ -0,0 +1,331 @@
+// Package gupshuptest provides an in-process fake of Gupshup's API for
+// integration tests. It records the messages it receives and posts
+// message-event callbacks to a webhook, like Gupshup does.
+package gupshuptest
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	wabaapi "github.com/panitaxx/gupshup-wabaapi"
+)
+
+// Failure makes every message to a destination fail with Code and Reason
+type Failure struct {
+	Code   int
+	Reason string
+}
+
+// Server is a fake Gupshup API. Configure the exported fields before sending
+// messages through it.
+type Server struct {
+	*httptest.Server
+
+	// App is the app name used in callbacks and the template list
+	App string
+	// APIKey, when set, is required in the apikey header
+	APIKey string
+	// WebhookURL receives the message-events, none are posted when empty
+	WebhookURL string
+	// Statuses are the message-events posted for every accepted message
+	Statuses []string
+	// EventDelay is the time between consecutive events of a message
+	EventDelay time.Duration
+	// Failures are keyed by destination, as sent by the client
+	Failures map[string]Failure
+	// Templates are returned by the template list endpoint
+	Templates []wabaapi.TemplateInfo
+	// Wallet is returned by the wallet balance endpoint
+	Wallet wabaapi.Wallet
+	// Unhealthy makes the app health endpoint report the app as unhealthy
+	Unhealthy bool
+	// Quality is returned by the app ratings endpoint
+	Quality wabaapi.QualityRating
+	// AppID is the id of App in the partner API
+	AppID string
+	// Profile is the business profile of App
+	Profile wabaapi.BusinessProfile
+
+	mu       sync.Mutex
+	messages []Message
+	media    map[string]File
+	events   sync.WaitGroup
+
+	callbackURL     string
+	subscriptions   []wabaapi.Subscription
+	optinRequired   bool
+	tokenGeneration int
+}
+
+// File is a file stored by the fake media endpoints
+type File struct {
+	ContentType string
+	Data        []byte
+}
+
+// NewServer starts a fake Gupshup server for app. Close it when done.
+func NewServer(app string) *Server {
+	s := &Server{
+		App:      app,
+		Statuses: []string{"enqueued", "sent", "delivered", "read"},
+		Failures: map[string]Failure{},
+		Wallet:   wabaapi.Wallet{Currency: "USD", CurrentBalance: 100},
+		Quality:  wabaapi.QualityRating{Rating: wabaapi.QualityGreen, Tier: "TIER_1K"},
+		AppID:    newID(),
+		media:    map[string]File{},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/sm/api/v1/msg", s.handleMessage)
+	mux.HandleFunc("/sm/api/v1/template/msg", s.handleMessage)
+	mux.HandleFunc("/sm/api/v1/template/list/", s.handleTemplates)
+	mux.HandleFunc("/sm/api/v2/wallet/balance", s.handleWallet)
+	mux.HandleFunc("/sm/api/v1/app/", s.handleApp)
+	mux.HandleFunc("/wa/", s.handleMedia)
+	mux.HandleFunc("/files/", s.handleFiles)
+	mux.HandleFunc("/partner/", s.handlePartner)
+	s.Server = httptest.NewServer(mux)
+	return s
+}
+
+// BaseURL is the URL to use as wabaapi.Client BaseURL
+func (s *Server) BaseURL() string {
+	return s.URL + "/sm/api/v1"
+}
+
+// NewClient returns a wabaapi.Client that sends to the fake
+func (s *Server) NewClient() *wabaapi.Client {
+	apiKey := s.APIKey
+	if apiKey == "" {
+		apiKey = "gupshuptest"
+	}
+	return &wabaapi.Client{APIKey: apiKey, BaseURL: s.BaseURL(), HTTPClient: s.Server.Client()}
+}
+
+// Messages returns the messages received so far
+func (s *Server) Messages() []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]Message, len(s.messages))
+	copy(out, s.messages)
+	return out
+}
+
+// Wait blocks until every pending callback has been posted
+func (s *Server) Wait() {
+	s.events.Wait()
+}
+
+// Close waits for the pending callbacks and stops the server
+func (s *Server) Close() {
+	s.Wait()
+	s.Server.Close()
+}
+
+func (s *Server) authorized(w http.ResponseWriter, r *http.Request) bool {
+	if s.APIKey != "" && r.Header.Get("apikey") != s.APIKey {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"status": "error", "message": "Authentication Failed"})
+		return false
+	}
+	return true
+}
+
+func (s *Server) handleMessage(w http.ResponseWriter, r *http.Request) {
+	if !s.authorized(w, r) {
+		return
+	}
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"status": "error", "message": "method not allowed"})
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"status": "error", "message": err.Error()})
+		return
+	}
+
+	msg, err := Decode(r.PostForm)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"status": "error", "message": err.Error()})
+		return
+	}
+	msg.ID = newID()
+	msg.ReceivedAt = time.Now()
+
+	s.mu.Lock()
+	s.messages = append(s.messages, msg)
+	s.mu.Unlock()
+
+	s.postEvents(msg)
+	writeJSON(w, http.StatusAccepted, wabaapi.SendResponse{Status: "submitted", MessageID: msg.ID})
+}
+
+func (s *Server) handleWallet(w http.ResponseWriter, r *http.Request) {
+	if !s.authorized(w, r) {
+		return
+	}
+	s.mu.Lock()
+	wallet := s.Wallet
+	s.mu.Unlock()
+	writeJSON(w, http.StatusOK, map[string]interface{}{"status": "success", "walletResponse": wallet})
+}
+
+// handleApp emulates GET /app/{app}/health, GET /app/{app}/ratings and the
+// business profile endpoints
+func (s *Server) handleApp(w http.ResponseWriter, r *http.Request) {
+	if !s.authorized(w, r) {
+		return
+	}
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/sm/api/v1/app/"), "/")
+	if len(parts) < 2 || parts[0] != s.App {
+		writeJSON(w, http.StatusNotFound, map[string]string{"status": "error", "message": "Invalid App Details"})
+		return
+	}
+	if parts[1] == "business" {
+		s.handleProfile(w, r, parts[2:])
+		return
+	}
+	if len(parts) != 2 {
+		http.NotFound(w, r)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch parts[1] {
+	case "health":
+		writeJSON(w, http.StatusOK, map[string]interface{}{"status": "success", "healthy": strconv.FormatBool(!s.Unhealthy)})
+	case "ratings":
+		writeJSON(w, http.StatusOK, s.Quality)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (s *Server) handleTemplates(w http.ResponseWriter, r *http.Request) {
+	if !s.authorized(w, r) {
+		return
+	}
+	app := strings.TrimPrefix(r.URL.Path, "/sm/api/v1/template/list/")
+	if app != s.App {
+		writeJSON(w, http.StatusNotFound, map[string]string{"status": "error", "message": "Invalid App Details"})
+		return
+	}
+
+	templates := s.Templates
+	if templates == nil {
+		templates = []wabaapi.TemplateInfo{}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"status": "success", "templates": templates})
+}
+
+// postEvents posts the message-events of msg from a goroutine. The settings
+// are copied first so the server can be reconfigured between sends
+func (s *Server) postEvents(msg Message) {
+	s.mu.Lock()
+	webhookURL, app, delay := s.WebhookURL, s.App, s.EventDelay
+	statuses := append([]string(nil), s.Statuses...)
+	failure, failed := s.Failures[msg.Destination]
+	s.mu.Unlock()
+	if webhookURL == "" {
+		return
+	}
+	if failed {
+		statuses = []string{"failed"}
+	}
+
+	waID := "wamid." + newID()
+	conversation, category := newID(), wabaapi.CategoryService
+	if msg.Type == "template" {
+		category = wabaapi.CategoryMarketing
+	}
+	s.events.Add(1)
+	go func() {
+		defer s.events.Done()
+		for _, status := range statuses {
+			time.Sleep(delay)
+			ev := wabaapi.MessageEventPayload{
+				ID:          waID,
+				GSID:        msg.ID,
+				Type:        status,
+				Destination: msg.Destination,
+			}
+			var payload interface{}
+			switch status {
+			case "enqueued":
+				ev.ID, ev.GSID = msg.ID, ""
+				payload = map[string]string{"whatsappMessageId": waID, "type": "session"}
+			case "failed":
+				ev.ID, ev.GSID = msg.ID, ""
+				payload = map[string]interface{}{"code": failure.Code, "reason": failure.Reason}
+			default:
+				payload = map[string]int64{"ts": time.Now().Unix()}
+				ev.Conversation = &wabaapi.Conversation{ID: conversation, ExpiresAt: time.Now().Add(24 * time.Hour).Truncate(time.Second), Type: category}
+				ev.Pricing = &wabaapi.Pricing{Policy: "CBP", Category: category}
+			}
+			ev.Payload, _ = json.Marshal(payload)
+			_, _ = postCallback(webhookURL, app, "message-event", ev)
+		}
+	}()
+}
+
+// PostCallback posts a callback of type with payload to the WebhookURL, as
+// Gupshup would, and returns the response status code
+func (s *Server) PostCallback(typ string, payload interface{}) (int, error) {
+	return postCallback(s.WebhookURL, s.App, typ, payload)
+}
+
+func postCallback(webhookURL string, app string, typ string, payload interface{}) (int, error) {
+	body, err := json.Marshal(map[string]interface{}{
+		"app":       app,
+		"timestamp": time.Now().UnixNano() / int64(time.Millisecond),
+		"version":   2,
+		"type":      typ,
+		"payload":   payload,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	resp, err := http.Post(webhookURL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	_, _ = ioutil.ReadAll(resp.Body)
+	return resp.StatusCode, nil
+}
+
+// PostText posts an inbound text message from phone to the WebhookURL
+func (s *Server) PostText(phone string, name string, text string) (int, error) {
+	return s.PostCallback("message", map[string]interface{}{
+		"id":      newID(),
+		"source":  phone,
+		"type":    "text",
+		"payload": map[string]string{"text": text},
+		"sender":  map[string]string{"phone": phone, "name": name},
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func newID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	h := hex.EncodeToString(b)
+	return h[:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:]
+}
@@ -0,0 +1,319 @@
+package gupshuptest
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/png"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ansel1/merry"
+	wabaapi "github.com/panitaxx/gupshup-wabaapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer(t *testing.T) {
+	var mu sync.Mutex
+	var events []wabaapi.MessageEventPayload
+	var texts []wabaapi.InboundText
+	webhook := httptest.NewServer(&wabaapi.WebhookHandler{Handle: func(msg *wabaapi.InboundMessage) error {
+		mu.Lock()
+		defer mu.Unlock()
+		switch p := msg.Payload.(type) {
+		case wabaapi.MessageEventPayload:
+			events = append(events, p)
+		case wabaapi.InboundMessagePayload:
+			texts = append(texts, p.Payload.(wabaapi.InboundText))
+		}
+		return nil
+	}})
+	defer webhook.Close()
+
+	srv := NewServer("testapp")
+	srv.APIKey = "secret"
+	srv.WebhookURL = webhook.URL
+	srv.Failures["34600000002"] = Failure{Code: 1002, Reason: "Number does not exist on WhatsApp"}
+	defer srv.Close()
+
+	client := srv.NewClient()
+	om := wabaapi.OutboundMessage{Channel: "whatsapp", Destination: "+34600000001", Source: "15555555555", SourceName: "testapp"}
+
+	values, err := om.Text("hello")
+	require.NoError(t, err)
+	id, err := client.Send(context.Background(), values)
+	require.NoError(t, err)
+
+	om.Destination = "+34600000002"
+	values, err = om.Template("tmpl-1", []string{"Ana"})
+	require.NoError(t, err)
+	_, err = client.Send(context.Background(), values)
+	require.NoError(t, err)
+
+	_, err = srv.PostText("34600000001", "Ana", "hi there")
+	require.NoError(t, err)
+	srv.Wait()
+
+	msgs := srv.Messages()
+	require.Len(t, msgs, 2)
+	assert.Equal(t, id, msgs[0].ID)
+	assert.Equal(t, wabaapi.TextMessage{Text: "hello"}, msgs[0].Payload)
+	assert.Equal(t, wabaapi.TemplateMessage{ID: "tmpl-1", Params: []string{"Ana"}}, msgs[1].Payload)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Len(t, events, 5)
+	assert.Equal(t, []wabaapi.InboundText{"hi there"}, texts)
+	for _, ev := range events {
+		if ev.Destination == "34600000002" {
+			assert.EqualError(t, ev.GetError(), "message-event failed:[1002] Number does not exist on WhatsApp")
+		}
+	}
+
+	srv.Templates = []wabaapi.TemplateInfo{{ID: "tmpl-1", ElementName: "welcome", Status: "APPROVED"}}
+	templates, err := client.Templates(context.Background(), "testapp")
+	require.NoError(t, err)
+	assert.Equal(t, srv.Templates, templates)
+
+	client.APIKey = "wrong"
+	_, err = client.Send(context.Background(), values)
+	assert.Error(t, err)
+}
+
+func TestServerReconfigureBetweenSends(t *testing.T) {
+	var mu sync.Mutex
+	var statuses []string
+	webhook := httptest.NewServer(&wabaapi.WebhookHandler{Handle: func(msg *wabaapi.InboundMessage) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if ev, ok := msg.Payload.(wabaapi.MessageEventPayload); ok {
+			statuses = append(statuses, ev.Type)
+		}
+		return nil
+	}})
+	defer webhook.Close()
+
+	srv := NewServer("testapp")
+	srv.WebhookURL = webhook.URL
+	srv.EventDelay = 10 * time.Millisecond
+	defer srv.Close()
+
+	client := srv.NewClient()
+	om := wabaapi.OutboundMessage{Channel: "whatsapp", Destination: "+34600000001", Source: "15555555555", SourceName: "testapp"}
+	values, err := om.Text("hello")
+	require.NoError(t, err)
+	_, err = client.Send(context.Background(), values)
+	require.NoError(t, err)
+
+	// the events of the first message are still being posted
+	srv.Statuses = []string{"enqueued"}
+	srv.EventDelay = 0
+	srv.Failures = map[string]Failure{}
+	_, err = client.Send(context.Background(), values)
+	require.NoError(t, err)
+	srv.Wait()
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Len(t, statuses, 5)
+}
+
+func TestAccountMonitor(t *testing.T) {
+	srv := NewServer("demo")
+	defer srv.Close()
+
+	var low []wabaapi.Wallet
+	var unhealthy int
+	var degraded [][2]wabaapi.QualityRating
+	mon := &wabaapi.AccountMonitor{
+		Client:       srv.NewClient(),
+		App:          "demo",
+		LowBalance:   10,
+		OnLowBalance: func(w wabaapi.Wallet) { low = append(low, w) },
+		OnUnhealthy:  func(wabaapi.AppHealth) { unhealthy++ },
+		OnQualityDegraded: func(prev, cur wabaapi.QualityRating) {
+			degraded = append(degraded, [2]wabaapi.QualityRating{prev, cur})
+		},
+	}
+
+	ctx := context.Background()
+	require.NoError(t, mon.Check(ctx))
+	assert.Empty(t, low)
+	assert.Zero(t, unhealthy)
+
+	srv.Wallet.CurrentBalance = 5
+	srv.Unhealthy = true
+	require.NoError(t, mon.Check(ctx))
+	require.NoError(t, mon.Check(ctx))
+	require.Len(t, low, 1)
+	assert.Equal(t, 5.0, low[0].CurrentBalance)
+	assert.Equal(t, 1, unhealthy)
+
+	srv.Wallet.CurrentBalance = 50
+	require.NoError(t, mon.Check(ctx))
+	srv.Wallet.CurrentBalance = 1
+	require.NoError(t, mon.Check(ctx))
+	assert.Len(t, low, 2)
+
+	srv.Quality.Rating = wabaapi.QualityYellow
+	require.NoError(t, mon.Check(ctx))
+	require.Len(t, degraded, 1)
+	assert.Equal(t, wabaapi.QualityGreen, degraded[0][0].Rating)
+
+	require.NoError(t, mon.HandleEvent(&wabaapi.InboundMessage{App: "demo", Type: "account-event",
+		Payload: wabaapi.AccountEventPayload{Type: "tier-update", Payload: map[string]interface{}{"currentLimit": "TIER_250", "event": "DOWNGRADE"}}}))
+	require.Len(t, degraded, 2)
+	assert.Equal(t, "TIER_250", degraded[1][1].Tier)
+	assert.Equal(t, "TIER_1K", degraded[1][1].PreviousTier)
+
+	_, err := srv.NewClient().AppHealth(ctx, "other")
+	assert.Error(t, err)
+}
+
+func TestPartnerConfigure(t *testing.T) {
+	srv := NewServer("demo")
+	defer srv.Close()
+	ctx := context.Background()
+
+	pc := &wabaapi.PartnerClient{BaseURL: srv.URL + "/partner", HTTPClient: srv.Server.Client()}
+	_, err := pc.Apps(ctx)
+	assert.Error(t, err)
+	require.NoError(t, pc.Login(ctx, "ops@example.com", "secret"))
+
+	app, err := pc.App(ctx, "demo")
+	require.NoError(t, err)
+	assert.Equal(t, srv.AppID, app.ID)
+	_, err = pc.App(ctx, "other")
+	assert.True(t, merry.Is(err, wabaapi.ErrUnknownApp))
+
+	settings := wabaapi.AppSettings{CallbackURL: "https://example.com/webhook", OptinRequired: true}
+	require.NoError(t, pc.Configure(ctx, app.ID, settings))
+	settings.CallbackURL = "https://example.com/v2/webhook"
+	require.NoError(t, pc.Configure(ctx, app.ID, settings))
+
+	callbackURL, subs, optin := srv.AppSettings()
+	assert.Equal(t, "https://example.com/v2/webhook", callbackURL)
+	assert.True(t, optin)
+	require.Len(t, subs, 1)
+	assert.Equal(t, "wabaapi", subs[0].Tag)
+	assert.Equal(t, wabaapi.DefaultModes, subs[0].Modes)
+	assert.Equal(t, 2, subs[0].Version)
+
+	listed, err := pc.Subscriptions(ctx, app.ID)
+	require.NoError(t, err)
+	assert.Equal(t, subs, listed)
+
+	srv.RotateAppToken()
+	require.NoError(t, pc.SetOptinRequired(ctx, app.ID, false), "an expired app token is fetched again")
+	_, _, optin = srv.AppSettings()
+	assert.False(t, optin)
+
+	assert.Error(t, pc.Configure(ctx, app.ID, wabaapi.AppSettings{CallbackURL: "not a url"}))
+	assert.Error(t, pc.SetCallbackURL(ctx, "missing", "https://example.com"))
+}
+
+func TestBusinessProfile(t *testing.T) {
+	srv := NewServer("demo")
+	defer srv.Close()
+	ctx := context.Background()
+	client := srv.NewClient()
+
+	require.NoError(t, client.UpdateBusinessProfile(ctx, "demo", wabaapi.BusinessProfile{
+		About:    "Open 8 to 20",
+		Email:    "hello@example.com",
+		Websites: []string{"https://example.com"},
+		Vertical: wabaapi.VerticalRetail,
+	}))
+	require.NoError(t, client.UpdateBusinessProfile(ctx, "demo", wabaapi.BusinessProfile{Description: "Corner shop"}))
+	assert.Error(t, client.UpdateBusinessProfile(ctx, "demo", wabaapi.BusinessProfile{}))
+
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 300, 300))))
+	photo := buf.Bytes()
+	assert.Error(t, client.SetProfilePhoto(ctx, "demo", bytes.NewReader([]byte("not an image"))))
+	require.NoError(t, client.SetProfilePhoto(ctx, "demo", bytes.NewReader(photo)))
+
+	profile, err := client.BusinessProfile(ctx, "demo")
+	require.NoError(t, err)
+	assert.Equal(t, "Open 8 to 20", profile.About)
+	assert.Equal(t, "Corner shop", profile.Description)
+	assert.Equal(t, []string{"https://example.com"}, profile.Websites)
+	require.NotEmpty(t, profile.PhotoURL)
+	f, ok := srv.File(path.Base(profile.PhotoURL))
+	require.True(t, ok)
+	assert.Equal(t, "image/png", f.ContentType)
+	assert.Equal(t, photo, f.Data)
+
+	u, err := client.SetProfilePhotoMedia(ctx, "demo", &wabaapi.MediaServerMedia{
+		Server: srv.MediaServer(),
+		Reader: ioutil.NopCloser(bytes.NewReader(photo)),
+	})
+	require.NoError(t, err)
+	f, ok = srv.File(path.Base(u))
+	require.True(t, ok)
+	assert.Equal(t, photo, f.Data)
+}
+
+func TestGupshupMediaServer(t *testing.T) {
+	srv := NewServer("demo")
+	defer srv.Close()
+	ctx := context.Background()
+	client := srv.NewClient()
+
+	om := &wabaapi.OutboundMessage{Channel: "whatsapp", Destination: "34600000001", Source: "15555555555", SourceName: "demo"}
+	pdf := append([]byte("%PDF-1.4\n"), make([]byte, 100)...)
+	values, err := om.DocumentMS(ctx, wabaapi.MediaServerMedia{
+		Server: &wabaapi.GupshupMediaServer{Client: client, AppID: srv.AppID},
+		Reader: ioutil.NopCloser(bytes.NewReader(pdf)),
+	}, "a.pdf")
+	require.NoError(t, err)
+	_, err = client.Send(ctx, values)
+	require.NoError(t, err)
+
+	msgs := srv.Messages()
+	require.Len(t, msgs, 1)
+	doc, ok := msgs[0].Payload.(wabaapi.DocumentMessage)
+	require.True(t, ok)
+	assert.Empty(t, doc.URL)
+	require.NotEmpty(t, doc.ID)
+	f, ok := srv.File(doc.ID)
+	require.True(t, ok)
+	assert.Equal(t, "application/pdf", f.ContentType)
+	assert.Equal(t, pdf, f.Data)
+
+	rc, ctype, err := client.DownloadMedia(ctx, srv.AppID, doc.ID)
+	require.NoError(t, err)
+	defer rc.Close()
+	data, err := ioutil.ReadAll(rc)
+	require.NoError(t, err)
+	assert.Equal(t, "application/pdf", ctype)
+	assert.Equal(t, pdf, data)
+
+	_, _, err = client.DownloadMedia(ctx, srv.AppID, "missing")
+	assert.Equal(t, http.StatusNotFound, merry.HTTPCode(err))
+	_, _, err = client.DownloadMedia(ctx, "demo", doc.ID)
+	assert.Equal(t, http.StatusNotFound, merry.HTTPCode(err), "the app name is not the app id")
+
+	var photo bytes.Buffer
+	require.NoError(t, png.Encode(&photo, image.NewRGBA(image.Rect(0, 0, 100, 100))))
+	values, err = om.ImageMS(ctx, wabaapi.MediaServerMedia{
+		Server: &wabaapi.GupshupMediaServer{Client: client, AppID: srv.AppID},
+		Reader: ioutil.NopCloser(bytes.NewReader(photo.Bytes())),
+	}, wabaapi.MediaServerMedia{})
+	require.NoError(t, err, "no preview is needed with media IDs")
+	_, err = client.Send(ctx, values)
+	require.NoError(t, err)
+	msgs = srv.Messages()
+	require.Len(t, msgs, 2)
+	img, ok := msgs[1].Payload.(wabaapi.ImageMessage)
+	require.True(t, ok)
+	assert.Empty(t, img.OriginalURL)
+	_, ok = srv.File(img.ID)
+	assert.True(t, ok)
+}
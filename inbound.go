@@ -8,13 +8,17 @@ import (
 )
 
 type InboundMessage struct {
-	App       string      `json:"app"`
-	Timestamp time.Time   `json:"timestamp"`
-	Type      string      `json:"type"`
-	Payload   interface{} `json:"payload"`
+	App       string       `json:"app"`
+	Timestamp time.Time    `json:"timestamp"`
+	Type      string       `json:"type"`
+	Payload   InboundEvent `json:"payload"`
 }
 
 func (m *InboundMessage) UnmarshalJSON(data []byte) error {
+	return DefaultInboundDecoder.decode(data, m)
+}
+
+func (d *InboundDecoder) decode(data []byte, m *InboundMessage) error {
 	type TMPMsg struct {
 		App       string          `json:"app"`
 		Timestamp int64           `json:"timestamp"`
@@ -28,15 +32,22 @@ func (m *InboundMessage) UnmarshalJSON(data []byte) error {
 		return err
 	}
 
-	t := time.Unix(tmp.Timestamp/1000, 0)
-
 	*m = InboundMessage{
 		App:       tmp.App,
-		Timestamp: t,
+		Timestamp: fromMillis(tmp.Timestamp),
 		Type:      tmp.Type,
 	}
 
-	var payload interface{}
+	if fn := d.eventDecoder(tmp.Type); fn != nil {
+		payload, err := fn(tmp.Payload)
+		if err != nil {
+			return merry.Errorf("failed to parse %s payload: %s", tmp.Type, err)
+		}
+		m.Payload = payload
+		return nil
+	}
+
+	var payload InboundEvent
 	switch tmp.Type {
 	case "user-event":
 		var tmpP UserEventPayload
@@ -65,17 +76,56 @@ func (m *InboundMessage) UnmarshalJSON(data []byte) error {
 		payload = tmpP
 	case "message":
 		var tmpP InboundMessagePayload
-		if err := json.Unmarshal(tmp.Payload, &tmpP); err != nil {
+		if err := d.decodeMessage(tmp.Payload, &tmpP); err != nil {
+			if _, ok := err.(*UnknownTypeError); ok {
+				return err
+			}
 			return merry.Errorf("failed to parse message payload: %s", err)
 		}
 		payload = tmpP
-
+	default:
+		if d.Strict {
+			return &UnknownTypeError{Event: tmp.Type}
+		}
+		payload = UnknownPayload{Type: tmp.Type, Raw: tmp.Payload}
 	}
 
 	m.Payload = payload
 	return nil
 }
 
+// MarshalJSON writes the message in Gupshup's v2 callback format
+func (m InboundMessage) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		App       string       `json:"app"`
+		Timestamp int64        `json:"timestamp"`
+		Version   int          `json:"version"`
+		Type      string       `json:"type"`
+		Payload   InboundEvent `json:"payload"`
+	}{
+		App:       m.App,
+		Timestamp: toMillis(m.Timestamp),
+		Version:   2,
+		Type:      m.Type,
+		Payload:   m.Payload,
+	})
+}
+
+// fromMillis converts Gupshup's millisecond timestamps, 0 is the zero time
+func fromMillis(ms int64) time.Time {
+	if ms == 0 {
+		return time.Time{}
+	}
+	return time.Unix(ms/1000, ms%1000*int64(time.Millisecond))
+}
+
+func toMillis(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano() / int64(time.Millisecond)
+}
+
 type UserEventPayload struct {
 	Phone string `json:"phone"`
 	Type  string `json:"type"`
@@ -99,7 +149,69 @@ type MessageEventPayload struct {
 	GSID        string          `json:"gsId"`
 	Type        string          `json:"type"`
 	Destination string          `json:"destination"`
-	Payload     json.RawMessage `json:"payload"`
+	Payload     json.RawMessage `json:"payload,omitempty"`
+	// Conversation and Pricing are sent with sent and delivered events
+	Conversation *Conversation `json:"conversation,omitempty"`
+	Pricing      *Pricing      `json:"pricing,omitempty"`
+}
+
+// Conversation categories
+const (
+	CategoryMarketing      = "marketing"
+	CategoryUtility        = "utility"
+	CategoryAuthentication = "authentication"
+	CategoryService        = "service"
+)
+
+// Conversation is the WhatsApp conversation a message opened or belongs to
+type Conversation struct {
+	ID        string
+	ExpiresAt time.Time
+	// Type is the category of the conversation
+	Type string
+}
+
+func (c Conversation) MarshalJSON() ([]byte, error) {
+	var expires int64
+	if !c.ExpiresAt.IsZero() {
+		expires = c.ExpiresAt.Unix()
+	}
+	return json.Marshal(struct {
+		ID        string `json:"id"`
+		ExpiresAt int64  `json:"expiresAt,omitempty"`
+		Type      string `json:"type"`
+	}{c.ID, expires, c.Type})
+}
+
+// UnmarshalJSON reads expiresAt, in seconds
+func (c *Conversation) UnmarshalJSON(data []byte) error {
+	var tmp struct {
+		ID        string `json:"id"`
+		ExpiresAt int64  `json:"expiresAt"`
+		Type      string `json:"type"`
+	}
+	if err := json.Unmarshal(data, &tmp); err != nil {
+		return err
+	}
+	*c = Conversation{ID: tmp.ID, Type: tmp.Type}
+	if tmp.ExpiresAt != 0 {
+		c.ExpiresAt = time.Unix(tmp.ExpiresAt, 0)
+	}
+	return nil
+}
+
+// Pricing is how the message is charged
+type Pricing struct {
+	// Policy is the pricing model, CBP for conversation based pricing
+	Policy   string `json:"policy"`
+	Category string `json:"category"`
+	// Billable is false for free conversations, nil when not sent
+	Billable *bool `json:"billable,omitempty"`
+}
+
+// IsBillable returns Billable, or true when it was not sent
+func (p Pricing) IsBillable() bool {
+	return p.Billable == nil || *p.Billable
 }
 
 func (msgEvent *MessageEventPayload) GetError() error {
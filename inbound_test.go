@@ -0,0 +1,134 @@
+package wabaapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"testing"
+	"testing/quick"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// randomInbound generates InboundMessages as they are decoded from callbacks
+type randomInbound struct {
+	InboundMessage
+}
+
+func (randomInbound) Generate(r *rand.Rand, size int) reflect.Value {
+	str := func() string {
+		v, _ := quick.Value(reflect.TypeOf(""), r)
+		return v.String()
+	}
+	millis := func() int64 { return 1500000000000 + r.Int63n(1000000000000) }
+
+	m := InboundMessage{App: str(), Timestamp: fromMillis(millis())}
+	switch r.Intn(5) {
+	case 0:
+		m.Type = "user-event"
+		m.Payload = UserEventPayload{Phone: str(), Type: "opted-in"}
+	case 1:
+		m.Type = "system-event"
+		m.Payload = SystemEventPayload{ID: str(), Status: "APPROVED", ElementName: str(), LanguageCode: "es"}
+	case 2:
+		m.Type = "account-event"
+		m.Payload = AccountEventPayload{Type: "status", Payload: map[string]interface{}{"status": str(), "count": float64(r.Intn(100))}}
+	case 3:
+		m.Type = "message-event"
+		m.Payload = MessageEventPayload{ID: str(), GSID: str(), Type: "delivered", Destination: str(),
+			Payload: json.RawMessage(fmt.Sprintf(`{"ts":%d}`, r.Int63()))}
+		if r.Intn(2) == 0 {
+			ev := m.Payload.(MessageEventPayload)
+			billable := r.Intn(2) == 0
+			ev.Conversation = &Conversation{ID: str(), ExpiresAt: time.Unix(millis()/1000, 0), Type: CategoryMarketing}
+			ev.Pricing = &Pricing{Policy: "CBP", Category: CategoryMarketing, Billable: &billable}
+			m.Payload = ev
+		}
+	case 4:
+		m.Type = "message"
+		msg := InboundMessagePayload{ID: str(), Source: str(), Sender: Sender{Phone: str(), Name: str(), CountryCode: "34", DialCode: "600000001"}}
+		if r.Intn(2) == 0 {
+			msg.Context = &Context{ID: str(), GsID: str()}
+		}
+		switch r.Intn(11) {
+		case 0:
+			msg.Type, msg.Payload = "text", InboundText(str())
+		case 1:
+			msg.Type, msg.Payload = "text", InboundButtonText(str())
+		case 2:
+			msg.Type = []string{"image", "audio", "video", "sticker", "file"}[r.Intn(5)]
+			msg.Payload = InboundMedia{Caption: str(), Name: str(), URL: str(), ContentType: str(), URLExpiry: fromMillis(millis()), Type: msg.Type}
+		case 3:
+			msg.Type, msg.Payload = "location", InboundLocation{Latitude: r.Float64()*180 - 90, Longitude: r.Float64()*360 - 180}
+		case 4:
+			var c Contact
+			c.Name.FormattedName = str()
+			c.Phones = append(c.Phones, struct {
+				Phone string `json:"phone"`
+				Type  string `json:"type"`
+			}{Phone: str(), Type: "CELL"})
+			msg.Type, msg.Payload = "contact", InboundContacts{c}
+		case 5:
+			msg.Type, msg.Payload = "list_reply", InboundListReply{Title: str(), ID: str(), Reply: str(), PostbackText: str(), Description: str()}
+		case 6:
+			msg.Type, msg.Payload = "button_reply", InboundButtonReply{Title: str(), ID: str(), Reply: str()}
+		case 7:
+			msg.Type, msg.Payload = "reaction", InboundReaction{Emoji: str(), MsgID: str()}
+		case 8:
+			msg.Type, msg.Payload = "order", InboundOrder{CatalogID: str(), Text: str(), Items: []InboundOrderItem{
+				{ProductRetailerID: str(), Quantity: r.Intn(10), ItemPrice: float64(r.Intn(100000)) / 100, Currency: "EUR"},
+			}}
+		case 9:
+			product := ReferredProduct{CatalogID: str(), ProductRetailerID: str()}
+			msg.Context = &Context{ID: str(), GsID: str(), ReferredProduct: &product}
+			msg.Type, msg.Payload = "text", InboundProductInquiry{Text: str(), Product: product}
+		case 10:
+			token := str()
+			response, _ := json.Marshal(map[string]string{"flow_token": token, "date": str()})
+			msg.Type, msg.Payload = "nfm_reply", InboundFlowReply{Name: "flow", Body: "Sent", ResponseJSON: response, FlowToken: token}
+		}
+		m.Payload = msg
+	}
+	return reflect.ValueOf(randomInbound{m})
+}
+
+func TestInboundMessageRoundTrip(t *testing.T) {
+	roundTrip := func(in randomInbound) bool {
+		data, err := json.Marshal(in.InboundMessage)
+		if err != nil {
+			t.Log(err)
+			return false
+		}
+		var out InboundMessage
+		if err := json.Unmarshal(data, &out); err != nil {
+			t.Log(err)
+			return false
+		}
+		return assert.Equal(t, in.InboundMessage, out)
+	}
+	assert.NoError(t, quick.Check(roundTrip, &quick.Config{MaxCount: 500}))
+}
+
+func TestInboundMessageMarshal(t *testing.T) {
+	wire := `{"app":"DemoApp","timestamp":1580227766370,"version":2,"type":"message","payload":{
+		"id":"ABEGkYaYVSEEAhAL3SLAWwHKeKrt6s3FKB0c","source":"918x98xx21x4","type":"text",
+		"payload":{"text":"Hi"},"sender":{"phone":"918x98xx21x4","name":"Smit","country_code":"91","dial_code":"8x98xx21x4"}}}`
+
+	var m InboundMessage
+	require.NoError(t, json.Unmarshal([]byte(wire), &m))
+	assert.Equal(t, int64(1580227766370), toMillis(m.Timestamp))
+
+	data, err := json.Marshal(m)
+	require.NoError(t, err)
+	assert.JSONEq(t, wire, string(data))
+
+	media := `{"caption":"","name":"a.jpg","url":"https://example.com/a.jpg","contentType":"image/jpeg","urlExpiry":1580227766371}`
+	var im InboundMedia
+	require.NoError(t, json.Unmarshal([]byte(media), &im))
+	data, err = json.Marshal(im)
+	require.NoError(t, err)
+	assert.JSONEq(t, media, string(data))
+}
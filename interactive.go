@@ -0,0 +1,233 @@
+package wabaapi
+
+import (
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/go-ozzo/ozzo-validation/v4/is"
+	"github.com/panitaxx/gupshup-wabaapi/phone"
+)
+
+// CTAURL creates an interactive message with a button that opens a URL
+func (om *OutboundMessage) CTAURL(cta CTAURLMessage) (url.Values, error) {
+	return om.Build(cta)
+}
+
+// LocationRequest creates an interactive message asking the user to share
+// their location. The reply is a location message, see LocationRequests
+func (om *OutboundMessage) LocationRequest(lr LocationRequestMessage) (url.Values, error) {
+	return om.Build(lr)
+}
+
+// InteractiveHeader is the optional header of interactive messages, a text or
+// a media. Create it with HeaderText, HeaderImage, HeaderVideo or HeaderDocument
+type InteractiveHeader struct {
+	Type     string `json:"type"`
+	Text     string `json:"text,omitempty"`
+	URL      string `json:"url,omitempty"`
+	Filename string `json:"filename,omitempty"`
+}
+
+func HeaderText(text string) *InteractiveHeader {
+	return &InteractiveHeader{Type: "text", Text: text}
+}
+
+func HeaderImage(url string) *InteractiveHeader {
+	return &InteractiveHeader{Type: "image", URL: url}
+}
+
+func HeaderVideo(url string) *InteractiveHeader {
+	return &InteractiveHeader{Type: "video", URL: url}
+}
+
+func HeaderDocument(url string, filename string) *InteractiveHeader {
+	return &InteractiveHeader{Type: "document", URL: url, Filename: filename}
+}
+
+func (h InteractiveHeader) Validate() error {
+	return validation.ValidateStruct(&h,
+		validation.Field(&h.Type, validation.Required, validation.In("text", "image", "video", "document")),
+		validation.Field(&h.Text, validation.When(h.Type == "text", validation.Required, validation.RuneLength(1, 60)).
+			Else(validation.Empty)),
+		validation.Field(&h.URL, validation.When(h.Type != "text", validation.Required, is.URL).
+			Else(validation.Empty)),
+	)
+}
+
+// CTAURLMessage is a message with a button that opens URL. Header and Footer are optional
+type CTAURLMessage struct {
+	MsgID       string             `json:"msgid,omitempty"`
+	Header      *InteractiveHeader `json:"header,omitempty"`
+	Body        string             `json:"body"`
+	Footer      string             `json:"footer,omitempty"`
+	DisplayText string             `json:"displayText"`
+	URL         string             `json:"url"`
+}
+
+func (cta CTAURLMessage) MessageType() string { return "cta_url" }
+func (cta CTAURLMessage) Encode() url.Values  { return encodeMessage(cta) }
+
+func (cta CTAURLMessage) Validate() error {
+	return validation.ValidateStruct(&cta,
+		validation.Field(&cta.Header),
+		validation.Field(&cta.Body, validation.Required, validation.RuneLength(1, 1024)),
+		validation.Field(&cta.Footer, validation.RuneLength(0, 60)),
+		validation.Field(&cta.DisplayText, validation.Required, validation.RuneLength(1, 20)),
+		validation.Field(&cta.URL, validation.Required, is.URL),
+	)
+}
+
+func (cta CTAURLMessage) MarshalJSON() ([]byte, error) {
+	type alias CTAURLMessage
+	return marshalWithType(cta.MessageType(), alias(cta))
+}
+
+// LocationRequestMessage asks the user to send their location
+type LocationRequestMessage struct {
+	MsgID string `json:"msgid,omitempty"`
+	Body  string `json:"body"`
+}
+
+func (lr LocationRequestMessage) MessageType() string { return "location_request_message" }
+func (lr LocationRequestMessage) Encode() url.Values  { return encodeMessage(lr) }
+
+func (lr LocationRequestMessage) Validate() error {
+	return validation.ValidateStruct(&lr,
+		validation.Field(&lr.Body, validation.Required, validation.RuneLength(1, 1024)),
+	)
+}
+
+func (lr LocationRequestMessage) MarshalJSON() ([]byte, error) {
+	type alias LocationRequestMessage
+	return marshalWithType(lr.MessageType(), alias(lr))
+}
+
+// RepliesTo reports whether msg is a reply to the message sent with id, the id
+// returned by Client.Send
+func (msg InboundMessagePayload) RepliesTo(id string) bool {
+	return msg.Context != nil && id != "" && (msg.Context.GsID == id || msg.Context.ID == id)
+}
+
+// LocationRequests correlates the locations users send with the location
+// requests sent to them. Locations are matched with the request they reply to
+// or, when they are not sent as a reply, with the last pending request to
+// the user. Requests that are never answered stay until Prune removes them.
+// It is safe for concurrent use
+type LocationRequests struct {
+	mu      sync.Mutex
+	pending map[string]locationRequest
+	// byPhone has the ids of the pending requests to every phone, oldest first
+	byPhone map[string][]string
+}
+
+type locationRequest struct {
+	phone string
+	ref   string
+	sent  time.Time
+}
+
+// locationPhone normalizes number with the phone package, so numbers with and
+// without '+' match. Numbers that cannot be normalized are used as given
+func locationPhone(number string) string {
+	if normalized, err := phone.Normalize(number, ""); err == nil {
+		return normalized
+	}
+	return strings.TrimPrefix(strings.TrimSpace(number), "+")
+}
+
+// Add registers a location request sent with id to the destination phone, a
+// number with country code like the Destination of the OutboundMessage.
+// ref is returned by Match, like an order or delivery id
+func (lr *LocationRequests) Add(id string, phone string, ref string) {
+	phone = locationPhone(phone)
+
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+	if lr.pending == nil {
+		lr.pending = map[string]locationRequest{}
+		lr.byPhone = map[string][]string{}
+	}
+	if prev, found := lr.pending[id]; found {
+		lr.removePhone(prev.phone, id)
+	}
+	lr.pending[id] = locationRequest{phone: phone, ref: ref, sent: time.Now()}
+	lr.byPhone[phone] = append(lr.byPhone[phone], id)
+}
+
+// Match returns the ref of the request msg replies to, and the location.
+// The request is removed, ok is false when msg is not a location or there
+// is no pending request for it
+func (lr *LocationRequests) Match(msg InboundMessagePayload) (ref string, loc InboundLocation, ok bool) {
+	loc, ok = msg.AsLocation()
+	if !ok {
+		return "", loc, false
+	}
+
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+
+	id := ""
+	if msg.Context != nil {
+		for _, ctxID := range []string{msg.Context.GsID, msg.Context.ID} {
+			if _, found := lr.pending[ctxID]; found {
+				id = ctxID
+				break
+			}
+		}
+	}
+	if id == "" {
+		if ids := lr.byPhone[locationPhone(msg.Source)]; len(ids) > 0 {
+			id = ids[len(ids)-1]
+		}
+	}
+
+	req, found := lr.pending[id]
+	if !found {
+		return "", loc, false
+	}
+	delete(lr.pending, id)
+	lr.removePhone(req.phone, id)
+	return req.ref, loc, true
+}
+
+// removePhone removes id from the pending requests of phone
+func (lr *LocationRequests) removePhone(phone string, id string) {
+	ids := lr.byPhone[phone]
+	for i, pending := range ids {
+		if pending == id {
+			ids = append(ids[:i:i], ids[i+1:]...)
+			break
+		}
+	}
+	if len(ids) == 0 {
+		delete(lr.byPhone, phone)
+		return
+	}
+	lr.byPhone[phone] = ids
+}
+
+// Prune removes the requests added before and returns how many were removed.
+// Call it periodically, like with the time the users have to answer
+func (lr *LocationRequests) Prune(before time.Time) int {
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+	n := 0
+	for id, req := range lr.pending {
+		if req.sent.Before(before) {
+			delete(lr.pending, id)
+			lr.removePhone(req.phone, id)
+			n++
+		}
+	}
+	return n
+}
+
+// Len returns the number of pending requests
+func (lr *LocationRequests) Len() int {
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+	return len(lr.pending)
+}
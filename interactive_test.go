@@ -0,0 +1,104 @@
+package wabaapi
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCTAURLMessage(t *testing.T) {
+	cta := CTAURLMessage{Header: HeaderText("Order 42"), Body: "your order is on its way", Footer: "thanks",
+		DisplayText: "Track", URL: "https://example.com/track/42"}
+	assert.NoError(t, cta.Validate())
+
+	val, err := json.Marshal(cta)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"type":"cta_url","header":{"type":"text","text":"Order 42"},"body":"your order is on its way",
+		"footer":"thanks","displayText":"Track","url":"https://example.com/track/42"}`, string(val))
+
+	invalid := cta
+	invalid.URL = "not a url"
+	assert.Error(t, invalid.Validate())
+
+	invalid = cta
+	invalid.DisplayText = "a display text that is too long"
+	assert.Error(t, invalid.Validate())
+
+	invalid = cta
+	invalid.Header = &InteractiveHeader{Type: "image"}
+	assert.Error(t, invalid.Validate())
+
+	invalid.Header = HeaderDocument("https://example.com/a.pdf", "a.pdf")
+	assert.NoError(t, invalid.Validate())
+}
+
+func TestLocationRequests(t *testing.T) {
+	var lr LocationRequests
+	lr.Add("gs-1", "34600000001", "order-1")
+	lr.Add("gs-2", "34600000002", "order-2")
+
+	decode := func(data string) InboundMessagePayload {
+		var msg InboundMessagePayload
+		require.NoError(t, json.Unmarshal([]byte(data), &msg))
+		return msg
+	}
+
+	reply := decode(`{"id":"in-1","source":"34600000001","type":"location","payload":{"latitude":40.4,"longitude":-3.7},"context":{"id":"wamid.1","gsId":"gs-1"}}`)
+	assert.True(t, reply.RepliesTo("gs-1"))
+	ref, loc, ok := lr.Match(reply)
+	assert.True(t, ok)
+	assert.Equal(t, "order-1", ref)
+	assert.Equal(t, InboundLocation{Latitude: 40.4, Longitude: -3.7}, loc)
+
+	_, _, ok = lr.Match(reply)
+	assert.False(t, ok)
+
+	noContext := decode(`{"id":"in-2","source":"34600000002","type":"location","payload":{"latitude":41.4,"longitude":2.1}}`)
+	ref, _, ok = lr.Match(noContext)
+	assert.True(t, ok)
+	assert.Equal(t, "order-2", ref)
+	assert.Equal(t, 0, lr.Len())
+
+	text := decode(`{"id":"in-3","source":"34600000002","type":"text","payload":{"text":"hi"}}`)
+	_, _, ok = lr.Match(text)
+	assert.False(t, ok)
+}
+
+func TestLocationRequestsPhones(t *testing.T) {
+	var lr LocationRequests
+	lr.Add("gs-1", "+34600000001", "order-1")
+	lr.Add("gs-2", "+34 600 000 001", "order-2")
+	require.Equal(t, 2, lr.Len())
+
+	var msg InboundMessagePayload
+	require.NoError(t, json.Unmarshal([]byte(`{"id":"in-1","source":"34600000001","type":"location","payload":{"latitude":40.4,"longitude":-3.7}}`), &msg))
+	ref, _, ok := lr.Match(msg)
+	assert.True(t, ok)
+	assert.Equal(t, "order-2", ref, "the last request is matched first")
+	ref, _, ok = lr.Match(msg)
+	assert.True(t, ok)
+	assert.Equal(t, "order-1", ref, "the replaced request stays matchable")
+	_, _, ok = lr.Match(msg)
+	assert.False(t, ok)
+	assert.Equal(t, 0, lr.Len())
+}
+
+func TestLocationRequestsPrune(t *testing.T) {
+	var lr LocationRequests
+	lr.Add("gs-1", "34600000001", "order-1")
+	lr.Add("gs-2", "34600000001", "order-2")
+
+	assert.Equal(t, 0, lr.Prune(time.Now().Add(-time.Minute)))
+	assert.Equal(t, 2, lr.Len())
+	assert.Equal(t, 2, lr.Prune(time.Now().Add(time.Second)))
+	assert.Equal(t, 0, lr.Len())
+
+	var msg InboundMessagePayload
+	require.NoError(t, json.Unmarshal([]byte(`{"id":"in-1","source":"34600000001","type":"location","payload":{"latitude":40.4,"longitude":-3.7}}`), &msg))
+	_, _, ok := lr.Match(msg)
+	assert.False(t, ok)
+	assert.Empty(t, lr.byPhone)
+}
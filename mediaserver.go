@@ -15,26 +15,100 @@ import (
 	"github.com/ansel1/merry"
 )
 
-//DefaultTimeout is the default timeout for the media server
+//DefaultTimeout is the timeout of the media server and client calls whose context has no deadline
 var DefaultTimeout = 120 * time.Second
 
+//MediaServer stores the files of the media messages. The methods take a
+//context since the context was added, adapt servers implementing the previous
+//methods with FromLegacy
 type MediaServer interface {
 	//GetFile returns a file from the media server and its content type
-	GetFile(requri string) (io.ReadCloser, string, error)
+	GetFile(ctx context.Context, requri string) (io.ReadCloser, string, error)
 	//PutFile uploads a file to the media server and returns the path to the file
-	PutFile(r io.Reader, contentType string) (string, error)
+	PutFile(ctx context.Context, r io.Reader, contentType string) (string, error)
 	//PutFileWithExt uploads a file to the media server and returns the path to the file
+	PutFileWithExt(ctx context.Context, r io.Reader, ext string) (string, error)
+}
+
+//LegacyMediaServer is the MediaServer interface without contexts
+type LegacyMediaServer interface {
+	GetFile(requri string) (io.ReadCloser, string, error)
+	PutFile(r io.Reader, contentType string) (string, error)
 	PutFileWithExt(r io.Reader, ext string) (string, error)
 }
 
+//FromLegacy adapts a LegacyMediaServer to MediaServer. The context is only
+//checked before every call, the legacy server cannot be cancelled once started
+func FromLegacy(ms LegacyMediaServer) MediaServer {
+	return legacyMediaServer{ms}
+}
+
+type legacyMediaServer struct {
+	ms LegacyMediaServer
+}
+
+func (l legacyMediaServer) GetFile(ctx context.Context, requri string) (io.ReadCloser, string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, "", merry.Wrap(err)
+	}
+	return l.ms.GetFile(requri)
+}
+
+func (l legacyMediaServer) PutFile(ctx context.Context, r io.Reader, contentType string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", merry.Wrap(err)
+	}
+	return l.ms.PutFile(r, contentType)
+}
+
+func (l legacyMediaServer) PutFileWithExt(ctx context.Context, r io.Reader, ext string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", merry.Wrap(err)
+	}
+	return l.ms.PutFileWithExt(r, ext)
+}
+
+//ToLegacy adapts a MediaServer to the signatures without context, every call
+//uses a context with DefaultTimeout
+func ToLegacy(ms MediaServer) LegacyMediaServer {
+	return contextMediaServer{ms}
+}
+
+type contextMediaServer struct {
+	ms MediaServer
+}
+
+func (c contextMediaServer) GetFile(requri string) (io.ReadCloser, string, error) {
+	//the reader may outlive the call so only the request is bounded
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultTimeout)
+	rc, ctype, err := c.ms.GetFile(ctx, requri)
+	if err != nil {
+		cancel()
+		return nil, "", err
+	}
+	return &cancelReadCloser{ReadCloser: rc, cancel: cancel}, ctype, nil
+}
+
+func (c contextMediaServer) PutFile(r io.Reader, contentType string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultTimeout)
+	defer cancel()
+	return c.ms.PutFile(ctx, r, contentType)
+}
+
+func (c contextMediaServer) PutFileWithExt(r io.Reader, ext string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultTimeout)
+	defer cancel()
+	return c.ms.PutFileWithExt(ctx, r, ext)
+}
+
 type MediaServerMedia struct {
 	Server      MediaServer
 	Reader      io.ReadCloser
 	ContentType string
 }
 
-func (media *MediaServerMedia) PutFile() (string, error) {
-	url, err := media.Server.PutFile(media.Reader, media.ContentType)
+func (media *MediaServerMedia) PutFile(ctx context.Context) (string, error) {
+	url, err := media.Server.PutFile(ctx, media.Reader, media.ContentType)
 	if err != nil {
 		return "", merry.Wrap(err)
 	}
@@ -42,39 +116,91 @@ func (media *MediaServerMedia) PutFile() (string, error) {
 	return url, nil
 }
 
+var _ MediaServer = (*GCSMediaServer)(nil)
+
 type GCSMediaServer struct {
 	Client     *gcs.Client
 	Bucket     string
 	PathPrefix string
 	URLHost    string
+	Observer   Observer
+}
+
+func (ms *GCSMediaServer) observe(ctx context.Context, op string) (context.Context, func(int64, error)) {
+	if ms.Observer == nil {
+		return ctx, func(int64, error) {}
+	}
+	return ms.Observer.MediaStart(ctx, op)
+}
+
+//withTimeout applies DefaultTimeout to contexts without a deadline
+func withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if _, ok := ctx.Deadline(); ok {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, DefaultTimeout)
 }
 
-func (ms *GCSMediaServer) GetFile(requri string) (io.ReadCloser, error) {
+func (ms *GCSMediaServer) GetFile(ctx context.Context, requri string) (io.ReadCloser, string, error) {
 	if ms.Client == nil || ms.Bucket == "" {
-		return nil, merry.New("GCSMediaServer not configured")
+		return nil, "", merry.New("GCSMediaServer not configured")
 	}
 
+	ctx, done := ms.observe(ctx, "get")
+	rc, ctype, err := ms.getFile(ctx, requri)
+	if err != nil {
+		done(0, err)
+		return nil, "", err
+	}
+	//bytes are reported when the reader is closed
+	return &observedReadCloser{countingReader: countingReader{Reader: rc}, closer: rc, done: done}, ctype, nil
+}
+
+func (ms *GCSMediaServer) getFile(ctx context.Context, requri string) (io.ReadCloser, string, error) {
 	filename := path.Join(ms.PathPrefix, requri)
 	obj := ms.Client.Bucket(ms.Bucket).Object(filename)
-	ctx, cancel := context.WithTimeout(context.Background(), DefaultTimeout)
-	defer cancel()
-	_, err := obj.Attrs(ctx)
+	ctx, cancel := withTimeout(ctx)
+	attrs, err := obj.Attrs(ctx)
 	if err != nil {
+		cancel()
 		if errors.Is(err, gcs.ErrObjectNotExist) {
-			return nil, merry.New("file not found").WithHTTPCode(http.StatusNotFound)
+			return nil, "", merry.New("file not found").WithHTTPCode(http.StatusNotFound)
 		}
-		return nil, merry.Wrap(err)
+		return nil, "", merry.Wrap(err)
 	}
 
-	return obj.NewReader(ctx)
+	r, err := obj.NewReader(ctx)
+	if err != nil {
+		cancel()
+		return nil, "", merry.Wrap(err)
+	}
+	//the context must live until the reader is closed
+	return &cancelReadCloser{ReadCloser: r, cancel: cancel}, attrs.ContentType, nil
+}
 
+type cancelReadCloser struct {
+	io.ReadCloser
+	cancel context.CancelFunc
 }
 
-func (ms *GCSMediaServer) PutFile(r io.Reader, contentType string) (string, error) {
+func (rc *cancelReadCloser) Close() error {
+	defer rc.cancel()
+	return rc.ReadCloser.Close()
+}
+
+func (ms *GCSMediaServer) PutFile(ctx context.Context, r io.Reader, contentType string) (string, error) {
 	if ms.Client == nil || ms.Bucket == "" {
 		return "", merry.New("GCSMediaServer not configured")
 	}
 
+	ctx, done := ms.observe(ctx, "put")
+	cr := &countingReader{Reader: r}
+	url, err := ms.putFile(ctx, cr, contentType)
+	done(cr.n, err)
+	return url, err
+}
+
+func (ms *GCSMediaServer) putFile(ctx context.Context, r io.Reader, contentType string) (string, error) {
 	if contentType == "" {
 		return "", merry.New("content type not specified")
 	}
@@ -86,22 +212,25 @@ func (ms *GCSMediaServer) PutFile(r io.Reader, contentType string) (string, erro
 
 	ext := ""
 	if len(exts) > 0 {
-		ext = "." + exts[0]
+		ext = exts[0]
 	}
 
 	fn := fmt.Sprintf("%s-%d%s", createSecureRandomString(10), time.Now().Unix(), ext)
 
 	filename := path.Join(ms.PathPrefix, fn)
 	obj := ms.Client.Bucket(ms.Bucket).Object(filename)
-	ctx, cancel := context.WithTimeout(context.Background(), DefaultTimeout)
+	ctx, cancel := withTimeout(ctx)
 	defer cancel()
 	w := obj.NewWriter(ctx)
-	defer w.Close()
-
 	w.ContentType = contentType
 
 	_, err = io.Copy(w, r)
 	if err != nil {
+		w.Close()
+		return "", merry.Wrap(err)
+	}
+	//the object is only created when the writer is closed
+	if err = w.Close(); err != nil {
 		return "", merry.Wrap(err)
 	}
 
@@ -117,7 +246,7 @@ func (ms *GCSMediaServer) PutFile(r io.Reader, contentType string) (string, erro
 	return fmt.Sprintf("%s/%s", ms.URLHost, filename), nil
 }
 
-func (ms *GCSMediaServer) PutFileWithExt(r io.Reader, ext string) (string, error) {
+func (ms *GCSMediaServer) PutFileWithExt(ctx context.Context, r io.Reader, ext string) (string, error) {
 	if ms.Client == nil || ms.Bucket == "" {
 		return "", merry.New("GCSMediaServer not configured")
 	}
@@ -131,7 +260,7 @@ func (ms *GCSMediaServer) PutFileWithExt(r io.Reader, ext string) (string, error
 		return "", merry.New("content type not found")
 	}
 
-	return ms.PutFile(r, ctype)
+	return ms.PutFile(ctx, r, ctype)
 }
 
 func createSecureRandomString(length int) string {
@@ -0,0 +1,97 @@
+package wabaapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	gcs "cloud.google.com/go/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/api/option"
+)
+
+// fakeGCS emulates the JSON API multipart upload and object metadata endpoints
+type fakeGCS struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+func (f *fakeGCS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch {
+	case r.Method == http.MethodPost && strings.HasPrefix(r.URL.Path, "/upload/storage/v1/b/"):
+		_, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		mr := multipart.NewReader(r.Body, params["boundary"])
+		var meta struct {
+			Name string `json:"name"`
+		}
+		part, err := mr.NextPart()
+		if err == nil {
+			err = json.NewDecoder(part).Decode(&meta)
+		}
+		var data []byte
+		if err == nil {
+			if part, err = mr.NextPart(); err == nil {
+				data, err = ioutil.ReadAll(part)
+			}
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		f.objects[meta.Name] = data
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"bucket": "media", "name": meta.Name, "size": strconv.Itoa(len(data))})
+	case r.Method == http.MethodGet && strings.HasPrefix(r.URL.Path, "/storage/v1/b/media/o/"):
+		name := strings.TrimPrefix(r.URL.Path, "/storage/v1/b/media/o/")
+		if _, ok := f.objects[name]; !ok {
+			http.Error(w, `{"error":{"code":404,"message":"not found"}}`, http.StatusNotFound)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"bucket": "media", "name": name, "mediaLink": "https://storage.example.com/" + name})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestGCSMediaServerPutFile(t *testing.T) {
+	fake := &fakeGCS{objects: map[string][]byte{}}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	ctx := context.Background()
+	client, err := gcs.NewClient(ctx, option.WithEndpoint(srv.URL+"/storage/v1/"), option.WithoutAuthentication(), option.WithHTTPClient(srv.Client()))
+	require.NoError(t, err)
+	defer client.Close()
+
+	ms := &GCSMediaServer{Client: client, Bucket: "media", PathPrefix: "wa", URLHost: "https://media.example.com"}
+	u, err := ms.PutFile(ctx, bytes.NewReader([]byte("%PDF-1.4")), "application/pdf")
+	require.NoError(t, err)
+
+	//the object exists once PutFile returns and its name has a single dot
+	require.True(t, strings.HasPrefix(u, "https://media.example.com/wa/"), u)
+	assert.True(t, strings.HasSuffix(u, ".pdf"), u)
+	assert.NotContains(t, u, "..")
+	name := strings.TrimPrefix(u, "https://media.example.com/")
+	assert.Equal(t, []byte("%PDF-1.4"), fake.objects[name])
+
+	ms.URLHost = ""
+	u, err = ms.PutFile(ctx, bytes.NewReader([]byte("%PDF-1.4")), "application/pdf")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(u, "https://storage.example.com/wa/"), u)
+}
@@ -0,0 +1,207 @@
+package wabaapi
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/ansel1/merry"
+)
+
+// MediaKind is the kind of WhatsApp media a file is sent as
+type MediaKind string
+
+const (
+	MediaImage    MediaKind = "image"
+	MediaAudio    MediaKind = "audio"
+	MediaVideo    MediaKind = "video"
+	MediaDocument MediaKind = "file"
+	MediaSticker  MediaKind = "sticker"
+)
+
+// MediaRule is the maximum size and allowed content types of a MediaKind
+type MediaRule struct {
+	MaxSize      int64
+	ContentTypes []string
+}
+
+func (rule MediaRule) allows(contentType string) bool {
+	for _, ct := range rule.ContentTypes {
+		if ct == contentType {
+			return true
+		}
+	}
+	return false
+}
+
+// MediaRules are WhatsApp's limits for every media kind
+var MediaRules = map[MediaKind]MediaRule{
+	MediaImage: {
+		MaxSize:      5 << 20,
+		ContentTypes: []string{"image/jpeg", "image/png"},
+	},
+	MediaAudio: {
+		MaxSize:      16 << 20,
+		ContentTypes: []string{"audio/aac", "audio/mp4", "audio/mpeg", "audio/amr", "audio/ogg"},
+	},
+	MediaVideo: {
+		MaxSize:      16 << 20,
+		ContentTypes: []string{"video/mp4", "video/3gpp"},
+	},
+	MediaDocument: {
+		MaxSize: 100 << 20,
+		ContentTypes: []string{
+			"text/plain",
+			"application/pdf",
+			"application/msword",
+			"application/vnd.ms-excel",
+			"application/vnd.ms-powerpoint",
+			"application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+			"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+			"application/vnd.openxmlformats-officedocument.presentationml.presentation",
+		},
+	},
+	MediaSticker: {
+		MaxSize:      500 << 10,
+		ContentTypes: []string{"image/webp"},
+	},
+}
+
+var (
+	// ErrMediaType is wrapped by MediaError when the content type is not allowed
+	ErrMediaType = merry.New("media type not supported")
+	// ErrMediaTooLarge is wrapped by MediaError when the file exceeds the size limit
+	ErrMediaTooLarge = merry.New("media too large")
+)
+
+// MediaError is returned when a file cannot be sent as its MediaKind
+type MediaError struct {
+	Kind        MediaKind
+	ContentType string
+	Size        int64
+	MaxSize     int64
+	Err         error
+}
+
+func (e *MediaError) Error() string {
+	if e.MaxSize > 0 {
+		return fmt.Sprintf("%s %s: %s, %d bytes exceeds %d", e.Kind, e.ContentType, e.Err, e.Size, e.MaxSize)
+	}
+	return fmt.Sprintf("%s %s: %s", e.Kind, e.ContentType, e.Err)
+}
+
+func (e *MediaError) Unwrap() error {
+	return e.Err
+}
+
+// Validate checks the media can be sent as kind. The content type is sniffed
+// from the first bytes and replaces ContentType, which is only trusted for
+// documents stored in generic containers like docx. Readers that are not
+// io.Seeker are buffered in memory up to the size limit.
+func (media *MediaServerMedia) Validate(kind MediaKind) error {
+	rule, ok := MediaRules[kind]
+	if !ok {
+		return merry.Errorf("unknown media kind %s", kind)
+	}
+	if media.Reader == nil {
+		return merry.New("media reader not specified")
+	}
+
+	var size int64
+	var body io.Reader
+	var head []byte
+	if s, ok := media.Reader.(io.Seeker); ok {
+		cur, err := s.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return merry.Wrap(err)
+		}
+		end, err := s.Seek(0, io.SeekEnd)
+		if err != nil {
+			return merry.Wrap(err)
+		}
+		if _, err = s.Seek(cur, io.SeekStart); err != nil {
+			return merry.Wrap(err)
+		}
+		size = end - cur
+
+		br := bufio.NewReaderSize(media.Reader, 512)
+		head, err = br.Peek(512)
+		if err != nil && err != io.EOF {
+			return merry.Wrap(err)
+		}
+		body = br
+	} else {
+		data, err := ioutil.ReadAll(io.LimitReader(media.Reader, rule.MaxSize+1))
+		if err != nil {
+			return merry.Wrap(err)
+		}
+		size = int64(len(data))
+		head = data
+		body = bytes.NewReader(data)
+	}
+
+	ctype := SniffContentType(head)
+	if kind == MediaDocument && isGenericContentType(ctype) && media.ContentType != "" {
+		ctype, _, _ = mime.ParseMediaType(media.ContentType)
+	}
+
+	if !rule.allows(ctype) {
+		return &MediaError{Kind: kind, ContentType: ctype, Size: size, Err: ErrMediaType}
+	}
+	if size > rule.MaxSize {
+		return &MediaError{Kind: kind, ContentType: ctype, Size: size, MaxSize: rule.MaxSize, Err: ErrMediaTooLarge}
+	}
+
+	media.Reader = struct {
+		io.Reader
+		io.Closer
+	}{body, media.Reader}
+	media.ContentType = ctype
+	return nil
+}
+
+func isGenericContentType(ctype string) bool {
+	switch ctype {
+	case "application/zip", "application/octet-stream", "text/plain":
+		return true
+	}
+	return false
+}
+
+// SniffContentType returns the content type of a file from its first bytes,
+// without parameters. It knows the formats WhatsApp accepts and falls back to
+// http.DetectContentType. Ogg files that are not opus are application/ogg.
+func SniffContentType(head []byte) string {
+	switch {
+	case len(head) >= 12 && string(head[4:8]) == "ftyp":
+		brand := string(head[8:12])
+		switch {
+		case strings.HasPrefix(brand, "3g"):
+			return "video/3gpp"
+		case brand == "M4A " || brand == "M4B ":
+			return "audio/mp4"
+		}
+		return "video/mp4"
+	case bytes.HasPrefix(head, []byte("OggS")):
+		if bytes.Contains(head, []byte("OpusHead")) {
+			return "audio/ogg"
+		}
+		return "application/ogg"
+	case bytes.HasPrefix(head, []byte("#!AMR")):
+		return "audio/amr"
+	case bytes.HasPrefix(head, []byte("ID3")):
+		return "audio/mpeg"
+	case len(head) >= 2 && head[0] == 0xFF && head[1]&0xF6 == 0xF0:
+		return "audio/aac"
+	case len(head) >= 2 && head[0] == 0xFF && head[1]&0xE0 == 0xE0:
+		return "audio/mpeg"
+	}
+
+	ctype, _, _ := mime.ParseMediaType(http.DetectContentType(head))
+	return ctype
+}
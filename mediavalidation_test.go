@@ -0,0 +1,93 @@
+package wabaapi
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/ansel1/merry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type memMediaServer struct {
+	contentType string
+	data        []byte
+}
+
+func (ms *memMediaServer) GetFile(ctx context.Context, requri string) (io.ReadCloser, string, error) {
+	return ioutil.NopCloser(bytes.NewReader(ms.data)), ms.contentType, nil
+}
+
+func (ms *memMediaServer) PutFile(ctx context.Context, r io.Reader, contentType string) (string, error) {
+	data, err := ioutil.ReadAll(r)
+	ms.data = data
+	ms.contentType = contentType
+	return "https://media.example.com/file", err
+}
+
+func (ms *memMediaServer) PutFileWithExt(ctx context.Context, r io.Reader, ext string) (string, error) {
+	return ms.PutFile(ctx, r, "")
+}
+
+func TestMediaValidate(t *testing.T) {
+	png := append([]byte("\x89PNG\r\n\x1a\n"), make([]byte, 100)...)
+
+	media := MediaServerMedia{Reader: ioutil.NopCloser(bytes.NewReader(png)), ContentType: "image/jpeg"}
+	require.NoError(t, media.Validate(MediaImage))
+	assert.Equal(t, "image/png", media.ContentType)
+	data, _ := ioutil.ReadAll(media.Reader)
+	assert.Equal(t, png, data)
+
+	media = MediaServerMedia{Reader: ioutil.NopCloser(bytes.NewReader(png))}
+	err := media.Validate(MediaVideo)
+	assert.True(t, merry.Is(err, ErrMediaType))
+	var mErr *MediaError
+	require.True(t, errors.As(err, &mErr))
+	assert.Equal(t, MediaVideo, mErr.Kind)
+
+	big := append([]byte("\xff\xd8\xff\xe0"), make([]byte, MediaRules[MediaImage].MaxSize)...)
+	media = MediaServerMedia{Reader: ioutil.NopCloser(bytes.NewReader(big))}
+	assert.True(t, merry.Is(media.Validate(MediaImage), ErrMediaTooLarge))
+}
+
+func TestDocumentMS(t *testing.T) {
+	om := &OutboundMessage{
+		Channel:     "whatsapp",
+		Destination: "+34600000001",
+		Source:      "15555555555",
+		SourceName:  "Our Company",
+	}
+	ms := &memMediaServer{}
+
+	values, err := om.DocumentMS(context.Background(), MediaServerMedia{Server: ms, Reader: ioutil.NopCloser(strings.NewReader("%PDF-1.4 ...")), ContentType: "text/plain"}, "invoice.pdf")
+	require.NoError(t, err)
+	assert.Equal(t, "application/pdf", ms.contentType)
+	assert.JSONEq(t, `{"type":"file","url":"https://media.example.com/file","filename":"invoice.pdf"}`, values.Get("message"))
+
+	_, err = om.AudioMS(context.Background(), MediaServerMedia{Server: ms, Reader: ioutil.NopCloser(strings.NewReader("OggS vorbis")), ContentType: "audio/ogg"})
+	assert.True(t, merry.Is(err, ErrMediaType))
+}
+
+func TestLegacyMediaServer(t *testing.T) {
+	ms := &memMediaServer{}
+	legacy := ToLegacy(ms)
+	url, err := legacy.PutFile(strings.NewReader("data"), "text/plain")
+	require.NoError(t, err)
+	assert.Equal(t, "https://media.example.com/file", url)
+
+	adapted := FromLegacy(legacy)
+	rc, ctype, err := adapted.GetFile(context.Background(), url)
+	require.NoError(t, err)
+	assert.Equal(t, "text/plain", ctype)
+	assert.NoError(t, rc.Close())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = adapted.PutFile(ctx, strings.NewReader("data"), "text/plain")
+	assert.True(t, errors.Is(err, context.Canceled))
+}
@@ -5,16 +5,23 @@ import (
 	"time"
 
 	"github.com/ansel1/merry"
+	"github.com/panitaxx/gupshup-wabaapi/phone"
 )
 
 type InboundMessagePayload struct {
-	ID      string      `json:"id"`
-	Source  string      `json:"source"`
-	Type    string      `json:"type"`
-	Payload interface{} `json:"payload"`
+	ID      string         `json:"id"`
+	Source  string         `json:"source"`
+	Type    string         `json:"type"`
+	Payload InboundContent `json:"payload"`
+	Sender  Sender         `json:"sender"`
+	Context *Context       `json:"context"`
 }
 
 func (msg *InboundMessagePayload) UnmarshalJSON(data []byte) error {
+	return DefaultInboundDecoder.decodeMessage(data, msg)
+}
+
+func (d *InboundDecoder) decodeMessage(data []byte, msg *InboundMessagePayload) error {
 	var tmp struct {
 		ID      string          `json:"id"`
 		Source  string          `json:"source"`
@@ -31,6 +38,18 @@ func (msg *InboundMessagePayload) UnmarshalJSON(data []byte) error {
 	msg.ID = tmp.ID
 	msg.Source = tmp.Source
 	msg.Type = tmp.Type
+	msg.Sender = tmp.Sender
+	msg.Context = tmp.Context
+	msg.Payload = nil
+
+	if fn := d.contentDecoder(msg.Type); fn != nil {
+		content, err := fn(tmp.Payload)
+		if err != nil {
+			return merry.Errorf("failed to parse %s payload: %s", msg.Type, err)
+		}
+		msg.Payload = content
+		return nil
+	}
 
 	switch msg.Type {
 	case "text":
@@ -41,10 +60,14 @@ func (msg *InboundMessagePayload) UnmarshalJSON(data []byte) error {
 		if err := json.Unmarshal(tmp.Payload, &tmpt); err != nil {
 			return merry.Errorf("failed to parse text payload: %s", err)
 		}
-		if tmpt.Type == "button" {
+		switch {
+		case tmpt.Type == "button":
 			msg.Payload = InboundButtonText(tmpt.Text)
+		case msg.Context != nil && msg.Context.ReferredProduct != nil:
+			msg.Payload = InboundProductInquiry{Text: tmpt.Text, Product: *msg.Context.ReferredProduct}
+		default:
+			msg.Payload = InboundText(tmpt.Text)
 		}
-		msg.Payload = InboundText(tmpt.Text)
 	case "audio", "video", "image", "sticker", "file":
 		var media InboundMedia
 		if err := json.Unmarshal(tmp.Payload, &media); err != nil {
@@ -65,7 +88,7 @@ func (msg *InboundMessagePayload) UnmarshalJSON(data []byte) error {
 		if err := json.Unmarshal(tmp.Payload, &contact); err != nil {
 			return merry.Errorf("failed to parse contact payload: %s", err)
 		}
-		msg.Payload = contact.Contacts
+		msg.Payload = InboundContacts(contact.Contacts)
 	case "list_reply":
 		var list InboundListReply
 		if err := json.Unmarshal(tmp.Payload, &list); err != nil {
@@ -78,22 +101,99 @@ func (msg *InboundMessagePayload) UnmarshalJSON(data []byte) error {
 			return merry.Errorf("failed to parse button_reply payload: %s", err)
 		}
 		msg.Payload = btn
-
+	case "reaction":
+		var reaction InboundReaction
+		if err := json.Unmarshal(tmp.Payload, &reaction); err != nil {
+			return merry.Errorf("failed to parse reaction payload: %s", err)
+		}
+		msg.Payload = reaction
+	case "nfm_reply":
+		var reply InboundFlowReply
+		if err := json.Unmarshal(tmp.Payload, &reply); err != nil {
+			return merry.Errorf("failed to parse nfm_reply payload: %s", err)
+		}
+		msg.Payload = reply
+	case "order":
+		var order InboundOrder
+		if err := json.Unmarshal(tmp.Payload, &order); err != nil {
+			return merry.Errorf("failed to parse order payload: %s", err)
+		}
+		msg.Payload = order
+	default:
+		if d.Strict {
+			return &UnknownTypeError{Event: "message", Type: msg.Type}
+		}
+		msg.Payload = UnknownPayload{Type: msg.Type, Raw: tmp.Payload}
 	}
 
 	return nil
 }
 
+// MarshalJSON writes the message in Gupshup's callback format
+func (msg InboundMessagePayload) MarshalJSON() ([]byte, error) {
+	var content interface{}
+	switch c := msg.Payload.(type) {
+	case InboundText:
+		content = map[string]string{"text": string(c)}
+	case InboundButtonText:
+		content = map[string]string{"text": string(c), "type": "button"}
+	case InboundContacts:
+		content = map[string]InboundContacts{"contacts": c}
+	case InboundProductInquiry:
+		content = map[string]string{"text": c.Text}
+	default:
+		content = c
+	}
+
+	return json.Marshal(struct {
+		ID      string      `json:"id"`
+		Source  string      `json:"source"`
+		Type    string      `json:"type"`
+		Payload interface{} `json:"payload"`
+		Sender  Sender      `json:"sender"`
+		Context *Context    `json:"context,omitempty"`
+	}{
+		ID:      msg.ID,
+		Source:  msg.Source,
+		Type:    msg.Type,
+		Payload: content,
+		Sender:  msg.Sender,
+		Context: msg.Context,
+	})
+}
+
 type Sender struct {
-	Phone       string `json:"phone"`
-	Name        string `json:"name"`
+	Phone string `json:"phone"`
+	Name  string `json:"name"`
+	// CountryCode is the country calling code of Phone, like "91"
 	CountryCode string `json:"country_code"`
-	DialCode    string `json:"dial_code"`
+	// DialCode is the national number of Phone, without the CountryCode
+	DialCode string `json:"dial_code"`
+}
+
+// Region returns the ISO 3166 region of the sender from its CountryCode
+func (s Sender) Region() string {
+	return phone.Region(s.CountryCode)
+}
+
+// NormalizePhone normalizes a number written by the sender, like a phone shared
+// in a text message, using the sender's country for national formats
+func (s Sender) NormalizePhone(number string) (string, error) {
+	return phone.Normalize(number, s.Region())
 }
 
 type Context struct {
 	ID   string `json:"id"`
 	GsID string `json:"gsId"`
+	// ReferredProduct is the product the user asked about from a catalog
+	// message, the text is decoded as InboundProductInquiry
+	ReferredProduct *ReferredProduct `json:"referred_product,omitempty"`
+}
+
+// ReferredProduct identifies a product of a catalog
+type ReferredProduct struct {
+	CatalogID         string `json:"catalog_id"`
+	ProductRetailerID string `json:"product_retailer_id"`
 }
 
 type InboundText string
@@ -126,13 +226,28 @@ func (media *InboundMedia) UnmarshalJSON(data []byte) error {
 	media.Name = tmp.Name
 	media.URL = tmp.URL
 	media.ContentType = tmp.ContentType
-	media.URLExpiry = time.Now().Add(time.Hour)
-
-	media.URLExpiry = time.Unix(tmp.URLExpiry/1000, 0)
+	media.URLExpiry = fromMillis(tmp.URLExpiry)
 
 	return nil
 }
 
+// MarshalJSON writes the media with urlExpiry in milliseconds, as Gupshup does
+func (media InboundMedia) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Caption     string `json:"caption"`
+		Name        string `json:"name"`
+		URL         string `json:"url"`
+		ContentType string `json:"contentType"`
+		URLExpiry   int64  `json:"urlExpiry,omitempty"`
+	}{
+		Caption:     media.Caption,
+		Name:        media.Name,
+		URL:         media.URL,
+		ContentType: media.ContentType,
+		URLExpiry:   toMillis(media.URLExpiry),
+	})
+}
+
 type InboundListReply struct {
 	Title        string `json:"title"`
 	ID           string `json:"id"`
@@ -147,11 +262,83 @@ type InboundButtonReply struct {
 	Reply string `json:"reply"`
 }
 
+// InboundReaction is an emoji reaction to a message. Emoji is empty when the
+// user removes the reaction
+type InboundReaction struct {
+	Emoji string `json:"emoji"`
+	// MsgID is the id of the message the user reacted to
+	MsgID string `json:"msgId"`
+}
+
+// InboundProductInquiry is a text the user sent about a catalog product
+type InboundProductInquiry struct {
+	Text    string
+	Product ReferredProduct
+}
+
+// InboundOrder is a cart sent from a catalog
+type InboundOrder struct {
+	CatalogID string             `json:"catalog_id"`
+	Text      string             `json:"text,omitempty"`
+	Items     []InboundOrderItem `json:"product_items"`
+}
+
+// Total returns the sum of the items price by quantity. Orders have a single currency
+func (o InboundOrder) Total() float64 {
+	var total float64
+	for _, it := range o.Items {
+		total += it.ItemPrice * float64(it.Quantity)
+	}
+	return total
+}
+
+type InboundOrderItem struct {
+	ProductRetailerID string  `json:"product_retailer_id"`
+	Quantity          int     `json:"quantity"`
+	ItemPrice         float64 `json:"item_price"`
+	Currency          string  `json:"currency"`
+}
+
+// UnmarshalJSON accepts quantity and item_price as numbers or numeric strings.
+// They are marshalled as numbers, so the JSON round trip of an order normalizes
+// the strings Gupshup sends for some items
+func (it *InboundOrderItem) UnmarshalJSON(data []byte) error {
+	var tmp struct {
+		ProductRetailerID string      `json:"product_retailer_id"`
+		Quantity          json.Number `json:"quantity"`
+		ItemPrice         json.Number `json:"item_price"`
+		Currency          string      `json:"currency"`
+	}
+	if err := json.Unmarshal(data, &tmp); err != nil {
+		return err
+	}
+
+	*it = InboundOrderItem{ProductRetailerID: tmp.ProductRetailerID, Currency: tmp.Currency}
+	if tmp.Quantity != "" {
+		q, err := tmp.Quantity.Int64()
+		if err != nil {
+			return merry.Errorf("invalid quantity %q", tmp.Quantity)
+		}
+		it.Quantity = int(q)
+	}
+	if tmp.ItemPrice != "" {
+		p, err := tmp.ItemPrice.Float64()
+		if err != nil {
+			return merry.Errorf("invalid item_price %q", tmp.ItemPrice)
+		}
+		it.ItemPrice = p
+	}
+	return nil
+}
+
 type InboundLocation struct {
 	Latitude  float64 `json:"latitude"`
 	Longitude float64 `json:"longitude"`
 }
 
+// InboundContacts are the contacts shared in a contact message
+type InboundContacts []Contact
+
 type Contact struct {
 	Addresses []struct {
 		City        string `json:"city"`
@@ -0,0 +1,83 @@
+package wabaapi
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func decodeFixture(t *testing.T, name string) (InboundMessagePayload, []byte) {
+	data, err := ioutil.ReadFile("testdata/" + name)
+	require.NoError(t, err)
+
+	var m InboundMessage
+	require.NoError(t, json.Unmarshal(data, &m))
+	msg, ok := m.AsMessage()
+	require.True(t, ok)
+	return msg, data
+}
+
+func TestInboundReaction(t *testing.T) {
+	msg, data := decodeFixture(t, "reaction.json")
+	reaction, ok := msg.AsReaction()
+	require.True(t, ok)
+	assert.Equal(t, InboundReaction{Emoji: "👍", MsgID: "8fe1fd97-2a6c-4a3b-aa88-a0d3d4d3f1b0"}, reaction)
+	assertRoundTrip(t, data)
+}
+
+func TestInboundOrder(t *testing.T) {
+	msg, raw := decodeFixture(t, "order.json")
+	order, ok := msg.AsOrder()
+	require.True(t, ok)
+	assert.Equal(t, "1033887044224112", order.CatalogID)
+	assert.Equal(t, "please deliver before 6pm", order.Text)
+	assert.Equal(t, []InboundOrderItem{
+		{ProductRetailerID: "sku-1", Quantity: 2, ItemPrice: 150.5, Currency: "INR"},
+		{ProductRetailerID: "sku-2", Quantity: 1, ItemPrice: 99, Currency: "INR"},
+	}, order.Items)
+	assert.Equal(t, 400.0, order.Total())
+
+	data, err := json.Marshal(msg)
+	require.NoError(t, err)
+	var decoded InboundMessagePayload
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, msg, decoded)
+
+	// the round trip normalizes the quantity and price strings to numbers
+	normalized := strings.Replace(string(raw), `"quantity": "1", "item_price": "99"`, `"quantity": 1, "item_price": 99`, 1)
+	require.NotEqual(t, string(raw), normalized)
+	assertRoundTrip(t, []byte(normalized))
+}
+
+func TestInboundProductInquiry(t *testing.T) {
+	msg, data := decodeFixture(t, "product_inquiry.json")
+	inquiry, ok := msg.AsProductInquiry()
+	require.True(t, ok)
+	assert.Equal(t, InboundProductInquiry{
+		Text:    "Is this available in blue?",
+		Product: ReferredProduct{CatalogID: "1033887044224112", ProductRetailerID: "sku-1"},
+	}, inquiry)
+	_, ok = msg.AsText()
+	assert.False(t, ok)
+	assertRoundTrip(t, data)
+}
+
+func assertRoundTrip(t *testing.T, data []byte) {
+	var m InboundMessage
+	require.NoError(t, json.Unmarshal(data, &m))
+	out, err := json.Marshal(m)
+	require.NoError(t, err)
+	assert.JSONEq(t, string(data), string(out))
+}
+
+func TestSenderNormalizePhone(t *testing.T) {
+	msg, _ := decodeFixture(t, "order.json")
+	assert.Equal(t, "IN", msg.Sender.Region())
+	number, err := msg.Sender.NormalizePhone("098765 43210")
+	require.NoError(t, err)
+	assert.Equal(t, "919876543210", number)
+}
@@ -0,0 +1,66 @@
+package wabaapi
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/url"
+)
+
+// Observer receives instrumentation events from Client, WebhookHandler and
+// GCSMediaServer. Every start method returns a context for the operation and a
+// func to call when it ends. Implementations must be safe for concurrent use.
+// The otelwaba module has an OpenTelemetry implementation.
+type Observer interface {
+	// SendStart is called before a message of messageType is posted. status is
+	// the HTTP status code, zero when the request was not sent
+	SendStart(ctx context.Context, messageType string) (context.Context, func(status int, err error))
+	// WebhookStart is called when a callback is received. eventType is the
+	// InboundMessage type and payloadType the Go type of its payload, both
+	// empty when err is a decoding error
+	WebhookStart(ctx context.Context) (context.Context, func(eventType string, payloadType string, err error))
+	// MediaStart is called before a media server operation: "get" or "put"
+	MediaStart(ctx context.Context, op string) (context.Context, func(bytes int64, err error))
+}
+
+// messageType returns the type of the message in values created by OutboundMessage
+func messageType(values url.Values) string {
+	if values.Get("template") != "" {
+		return "template"
+	}
+	var head struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal([]byte(values.Get("message")), &head); err != nil || head.Type == "" {
+		return "unknown"
+	}
+	return head.Type
+}
+
+// countingReader counts the bytes read through it
+type countingReader struct {
+	io.Reader
+	n int64
+}
+
+func (cr *countingReader) Read(p []byte) (int, error) {
+	n, err := cr.Reader.Read(p)
+	cr.n += int64(n)
+	return n, err
+}
+
+// observedReadCloser reports the bytes read when it is closed
+type observedReadCloser struct {
+	countingReader
+	closer io.Closer
+	done   func(bytes int64, err error)
+}
+
+func (rc *observedReadCloser) Close() error {
+	err := rc.closer.Close()
+	if rc.done != nil {
+		rc.done(rc.n, err)
+		rc.done = nil
+	}
+	return err
+}
@@ -0,0 +1,71 @@
+package wabaapi
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recordingObserver struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (ro *recordingObserver) add(ev string) {
+	ro.mu.Lock()
+	defer ro.mu.Unlock()
+	ro.events = append(ro.events, ev)
+}
+
+func (ro *recordingObserver) SendStart(ctx context.Context, messageType string) (context.Context, func(int, error)) {
+	return ctx, func(status int, err error) {
+		ro.add(messageType + " " + http.StatusText(status))
+	}
+}
+
+func (ro *recordingObserver) WebhookStart(ctx context.Context) (context.Context, func(string, string, error)) {
+	return ctx, func(eventType string, payloadType string, err error) {
+		if err != nil {
+			ro.add("webhook error")
+			return
+		}
+		ro.add(eventType + " " + payloadType)
+	}
+}
+
+func (ro *recordingObserver) MediaStart(ctx context.Context, op string) (context.Context, func(int64, error)) {
+	return ctx, func(int64, error) {}
+}
+
+func TestObserver(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte(`{"status":"submitted","messageId":"id-1"}`))
+	}))
+	defer srv.Close()
+
+	obs := &recordingObserver{}
+	client := &Client{APIKey: "key", BaseURL: srv.URL, Observer: obs}
+	om := &OutboundMessage{Channel: "whatsapp", Destination: "+34600000001", Source: "15555555555", SourceName: "app"}
+
+	values, err := om.Text("hi")
+	require.NoError(t, err)
+	_, err = client.Send(context.Background(), values)
+	require.NoError(t, err)
+
+	wh := &WebhookHandler{Observer: obs}
+	for _, body := range []string{
+		`{"app":"app","timestamp":1639000000000,"type":"user-event","payload":{"phone":"34600000001","type":"opted-in"}}`,
+		`{"app":`,
+	} {
+		wh.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body)))
+	}
+
+	assert.Equal(t, []string{"text Accepted", "user-event wabaapi.UserEventPayload", "webhook error"}, obs.events)
+}
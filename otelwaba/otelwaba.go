@@ -0,0 +1,140 @@
+// Package otelwaba implements wabaapi.Observer with OpenTelemetry spans and
+// metrics. It is a separate module so the OpenTelemetry dependency is only
+// pulled by applications that enable it.
+//
+//	obs, err := otelwaba.New(otel.GetTracerProvider(), otel.GetMeterProvider())
+//	client := &wabaapi.Client{APIKey: key, Observer: obs}
+package otelwaba
+
+import (
+	"context"
+	"time"
+
+	wabaapi "github.com/panitaxx/gupshup-wabaapi"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ScopeName is the instrumentation scope of the spans and metrics
+const ScopeName = "github.com/panitaxx/gupshup-wabaapi"
+
+var _ wabaapi.Observer = (*Observer)(nil)
+
+// Observer records spans and metrics for sends, webhooks and media operations
+type Observer struct {
+	tracer trace.Tracer
+
+	sends          metric.Int64Counter
+	sendDuration   metric.Float64Histogram
+	webhookEvents  metric.Int64Counter
+	decodeFailures metric.Int64Counter
+	mediaBytes     metric.Int64Counter
+	mediaDuration  metric.Float64Histogram
+	mediaErrors    metric.Int64Counter
+}
+
+// New creates an Observer with the given providers
+func New(tp trace.TracerProvider, mp metric.MeterProvider) (*Observer, error) {
+	meter := mp.Meter(ScopeName)
+	obs := &Observer{tracer: tp.Tracer(ScopeName)}
+
+	var err error
+	if obs.sends, err = meter.Int64Counter("wabaapi.send.count",
+		metric.WithDescription("Messages sent to Gupshup by message type and status code")); err != nil {
+		return nil, err
+	}
+	if obs.sendDuration, err = meter.Float64Histogram("wabaapi.send.duration",
+		metric.WithDescription("Duration of the send requests"), metric.WithUnit("s")); err != nil {
+		return nil, err
+	}
+	if obs.webhookEvents, err = meter.Int64Counter("wabaapi.webhook.count",
+		metric.WithDescription("Callbacks received by event and payload type")); err != nil {
+		return nil, err
+	}
+	if obs.decodeFailures, err = meter.Int64Counter("wabaapi.webhook.decode_failures",
+		metric.WithDescription("Callbacks that could not be decoded")); err != nil {
+		return nil, err
+	}
+	if obs.mediaBytes, err = meter.Int64Counter("wabaapi.media.bytes",
+		metric.WithDescription("Bytes transferred by the media server"), metric.WithUnit("By")); err != nil {
+		return nil, err
+	}
+	if obs.mediaDuration, err = meter.Float64Histogram("wabaapi.media.duration",
+		metric.WithDescription("Duration of the media server operations"), metric.WithUnit("s")); err != nil {
+		return nil, err
+	}
+	if obs.mediaErrors, err = meter.Int64Counter("wabaapi.media.errors",
+		metric.WithDescription("Failed media server operations")); err != nil {
+		return nil, err
+	}
+	return obs, nil
+}
+
+// SendStart starts a span for an outbound message
+func (obs *Observer) SendStart(ctx context.Context, messageType string) (context.Context, func(int, error)) {
+	start := time.Now()
+	ctx, span := obs.tracer.Start(ctx, "wabaapi.send", trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("wabaapi.message.type", messageType)))
+
+	return ctx, func(status int, err error) {
+		attrs := metric.WithAttributes(
+			attribute.String("wabaapi.message.type", messageType),
+			attribute.Int("http.status_code", status),
+		)
+		obs.sends.Add(ctx, 1, attrs)
+		obs.sendDuration.Record(ctx, time.Since(start).Seconds(), attrs)
+
+		span.SetAttributes(attribute.Int("http.status_code", status))
+		end(span, err)
+	}
+}
+
+// WebhookStart starts a span for a received callback
+func (obs *Observer) WebhookStart(ctx context.Context) (context.Context, func(string, string, error)) {
+	ctx, span := obs.tracer.Start(ctx, "wabaapi.webhook", trace.WithSpanKind(trace.SpanKindServer))
+
+	return ctx, func(eventType string, payloadType string, err error) {
+		if eventType == "" && err != nil {
+			obs.decodeFailures.Add(ctx, 1)
+			end(span, err)
+			return
+		}
+
+		attrs := []attribute.KeyValue{
+			attribute.String("wabaapi.event.type", eventType),
+			attribute.String("wabaapi.payload.type", payloadType),
+		}
+		obs.webhookEvents.Add(ctx, 1, metric.WithAttributes(append(attrs, attribute.Bool("error", err != nil))...))
+		span.SetAttributes(attrs...)
+		end(span, err)
+	}
+}
+
+// MediaStart starts a span for a media server operation
+func (obs *Observer) MediaStart(ctx context.Context, op string) (context.Context, func(int64, error)) {
+	start := time.Now()
+	ctx, span := obs.tracer.Start(ctx, "wabaapi.media."+op, trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("wabaapi.media.op", op)))
+
+	return ctx, func(bytes int64, err error) {
+		attrs := metric.WithAttributes(attribute.String("wabaapi.media.op", op))
+		obs.mediaBytes.Add(ctx, bytes, attrs)
+		obs.mediaDuration.Record(ctx, time.Since(start).Seconds(), attrs)
+		if err != nil {
+			obs.mediaErrors.Add(ctx, 1, attrs)
+		}
+
+		span.SetAttributes(attribute.Int64("wabaapi.media.bytes", bytes))
+		end(span, err)
+	}
+}
+
+func end(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,86 @@
+package otelwaba
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	wabaapi "github.com/panitaxx/gupshup-wabaapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestObserver(t *testing.T) {
+	spans := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans))
+	reader := sdkmetric.NewManualReader()
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+
+	obs, err := New(tp, mp)
+	require.NoError(t, err)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"status":"error","message":"Invalid Destination"}`))
+	}))
+	defer srv.Close()
+
+	client := &wabaapi.Client{APIKey: "key", BaseURL: srv.URL, Observer: obs}
+	om := &wabaapi.OutboundMessage{Channel: "whatsapp", Destination: "+34600000001", Source: "15555555555", SourceName: "app"}
+	values, err := om.Template("tmpl", nil)
+	require.NoError(t, err)
+	_, err = client.Send(context.Background(), values)
+	require.Error(t, err)
+
+	wh := &wabaapi.WebhookHandler{Observer: obs}
+	wh.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/", strings.NewReader("{")))
+
+	var handled trace.SpanContext
+	wh.HandleContext = func(ctx context.Context, msg *wabaapi.InboundMessage) error {
+		handled = trace.SpanContextFromContext(ctx)
+		return nil
+	}
+	wh.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/",
+		strings.NewReader(`{"app":"app","timestamp":1639000000000,"version":2,"type":"message","payload":{"id":"m1","source":"34600000001","type":"text","payload":{"text":"hi"},"sender":{"phone":"34600000001","name":"Ana"}}}`)))
+
+	ended := spans.Ended()
+	require.Len(t, ended, 3)
+	assert.Equal(t, "wabaapi.send", ended[0].Name())
+	assert.Equal(t, "wabaapi.webhook", ended[1].Name())
+	assert.Equal(t, ended[2].SpanContext().SpanID(), handled.SpanID())
+	for _, kv := range ended[0].Attributes() {
+		if kv.Key == "http.status_code" {
+			assert.Equal(t, attribute.INT64, kv.Value.Type())
+		}
+	}
+
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(context.Background(), &rm))
+	names := map[string]bool{}
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			names[m.Name] = true
+		}
+	}
+	assert.True(t, names["wabaapi.send.count"])
+	assert.True(t, names["wabaapi.webhook.decode_failures"])
+
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if sum, ok := m.Data.(metricdata.Sum[int64]); ok && m.Name == "wabaapi.send.count" {
+				status, ok := sum.DataPoints[0].Attributes.Value("http.status_code")
+				require.True(t, ok)
+				assert.Equal(t, attribute.INT64, status.Type())
+				assert.Equal(t, int64(http.StatusBadRequest), status.AsInt64())
+			}
+		}
+	}
+}
@@ -0,0 +1,333 @@
+package wabaapi
+
+import (
+	"encoding/json"
+	"net/url"
+	"unicode/utf8"
+
+	"github.com/ansel1/merry"
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/go-ozzo/ozzo-validation/v4/is"
+)
+
+// Outbound is a typed outbound message. Build it with OutboundMessage.Build
+// to get the values for Client.Send. Outbound messages marshal to JSON in
+// Gupshup's message format, including the type, so they can be logged,
+// stored and read back with UnmarshalOutbound.
+type Outbound interface {
+	// MessageType is the Gupshup message type: text, image, audio, video,
+	// file, list, quick_reply, product_details, cta_url,
+	// location_request_message, flow or template
+	MessageType() string
+	// Encode returns the message fields of the request, without the
+	// OutboundMessage defaults
+	Encode() url.Values
+}
+
+// Build validates msg, unless DoNotValidate is set, and returns the values to send it
+func (om *OutboundMessage) Build(msg Outbound) (url.Values, error) {
+	values, err := om.defaultValues()
+	if err != nil {
+		return nil, err
+	}
+
+	if !om.DoNotValidate {
+		if v, ok := msg.(validation.Validatable); ok {
+			if err := v.Validate(); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	for k, vs := range msg.Encode() {
+		values[k] = vs
+	}
+	return values, nil
+}
+
+// encodeMessage returns the values with msg as the message JSON
+func encodeMessage(msg Outbound) url.Values {
+	txt, _ := json.Marshal(msg)
+	return url.Values{"message": {string(txt)}}
+}
+
+// marshalWithType marshals v adding the type field
+func marshalWithType(typ string, v interface{}) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	fields["type"], _ = json.Marshal(typ)
+	return json.Marshal(fields)
+}
+
+// TextMessage is a text message
+type TextMessage struct {
+	Text string `json:"text"`
+}
+
+func (m TextMessage) MessageType() string { return "text" }
+func (m TextMessage) Encode() url.Values  { return encodeMessage(m) }
+
+func (m TextMessage) Validate() error {
+	if m.Text == "" {
+		return merry.New("text cannot be empty")
+	}
+	if utf8.RuneCountInString(m.Text) > MaxTextLength {
+		return merry.Errorf("text is longer than %d characters, use TextParts", MaxTextLength)
+	}
+	return nil
+}
+
+func (m TextMessage) MarshalJSON() ([]byte, error) {
+	type alias TextMessage
+	return marshalWithType(m.MessageType(), alias(m))
+}
+
+// ImageMessage is an image message, sent from OriginalURL and PreviewURL or
+// by the ID of a media uploaded with Client.UploadMedia
+type ImageMessage struct {
+	ID          string `json:"id,omitempty"`
+	OriginalURL string `json:"originalUrl,omitempty"`
+	PreviewURL  string `json:"previewUrl,omitempty"`
+	Caption     string `json:"caption,omitempty"`
+}
+
+func (m ImageMessage) MessageType() string { return "image" }
+func (m ImageMessage) Encode() url.Values  { return encodeMessage(m) }
+
+func (m ImageMessage) Validate() error {
+	return validation.ValidateStruct(&m,
+		validation.Field(&m.ID, validation.When(m.OriginalURL == "", validation.Required).Else(validation.Empty)),
+		validation.Field(&m.OriginalURL, validation.When(m.ID == "", validation.Required, is.URL)),
+		validation.Field(&m.PreviewURL, validation.When(m.ID == "", validation.Required, is.URL).Else(validation.Empty)),
+	)
+}
+
+func (m ImageMessage) MarshalJSON() ([]byte, error) {
+	type alias ImageMessage
+	return marshalWithType(m.MessageType(), alias(m))
+}
+
+// AudioMessage is an audio message, sent from URL or by media ID
+type AudioMessage struct {
+	ID  string `json:"id,omitempty"`
+	URL string `json:"url,omitempty"`
+}
+
+func (m AudioMessage) MessageType() string { return "audio" }
+func (m AudioMessage) Encode() url.Values  { return encodeMessage(m) }
+
+func (m AudioMessage) Validate() error {
+	return validateMediaRef(m.ID, m.URL)
+}
+
+func (m AudioMessage) MarshalJSON() ([]byte, error) {
+	type alias AudioMessage
+	return marshalWithType(m.MessageType(), alias(m))
+}
+
+// VideoMessage is a video message, sent from URL or by media ID
+type VideoMessage struct {
+	ID      string `json:"id,omitempty"`
+	URL     string `json:"url,omitempty"`
+	Caption string `json:"caption"`
+}
+
+func (m VideoMessage) MessageType() string { return "video" }
+func (m VideoMessage) Encode() url.Values  { return encodeMessage(m) }
+
+func (m VideoMessage) Validate() error {
+	return validateMediaRef(m.ID, m.URL)
+}
+
+func (m VideoMessage) MarshalJSON() ([]byte, error) {
+	type alias VideoMessage
+	return marshalWithType(m.MessageType(), alias(m))
+}
+
+// DocumentMessage is a document, Gupshup's file message, sent from URL or by media ID
+type DocumentMessage struct {
+	ID       string `json:"id,omitempty"`
+	URL      string `json:"url,omitempty"`
+	Filename string `json:"filename"`
+}
+
+func (m DocumentMessage) MessageType() string { return "file" }
+func (m DocumentMessage) Encode() url.Values  { return encodeMessage(m) }
+
+func (m DocumentMessage) Validate() error {
+	return validateMediaRef(m.ID, m.URL)
+}
+
+func (m DocumentMessage) MarshalJSON() ([]byte, error) {
+	type alias DocumentMessage
+	return marshalWithType(m.MessageType(), alias(m))
+}
+
+// validateMediaRef checks a media is sent either by id or from a URL
+func validateMediaRef(id string, u string) error {
+	switch {
+	case id == "" && u == "":
+		return merry.New("media url or id is required")
+	case id != "" && u != "":
+		return merry.New("media url and id cannot be both set")
+	}
+	return validation.Validate(u, is.URL)
+}
+
+// TemplateMessage is an approved template with its params in order
+type TemplateMessage struct {
+	ID     string   `json:"id"`
+	Params []string `json:"params"`
+}
+
+func (m TemplateMessage) MessageType() string { return "template" }
+
+func (m TemplateMessage) Encode() url.Values {
+	type alias TemplateMessage
+	if m.Params == nil {
+		m.Params = []string{}
+	}
+	txt, _ := json.Marshal(alias(m))
+	return url.Values{"template": {string(txt)}}
+}
+
+func (m TemplateMessage) Validate() error {
+	return validation.ValidateStruct(&m,
+		validation.Field(&m.ID, validation.Required),
+	)
+}
+
+func (m TemplateMessage) MarshalJSON() ([]byte, error) {
+	type alias TemplateMessage
+	return marshalWithType(m.MessageType(), alias(m))
+}
+
+// UnmarshalOutbound decodes the JSON of an Outbound message, as written by
+// json.Marshal or sent in the message field of a request
+func UnmarshalOutbound(data []byte) (Outbound, error) {
+	var head struct {
+		Type    string `json:"type"`
+		SubType string `json:"subType"`
+		Content struct {
+			Type string `json:"type"`
+		} `json:"content"`
+	}
+	if err := json.Unmarshal(data, &head); err != nil {
+		return nil, merry.Errorf("failed to parse message: %s", err)
+	}
+
+	var msg Outbound
+	var err error
+	switch head.Type {
+	case "text":
+		var m TextMessage
+		err = json.Unmarshal(data, &m)
+		msg = m
+	case "image":
+		var m ImageMessage
+		err = json.Unmarshal(data, &m)
+		msg = m
+	case "audio":
+		var m AudioMessage
+		err = json.Unmarshal(data, &m)
+		msg = m
+	case "video":
+		var m VideoMessage
+		err = json.Unmarshal(data, &m)
+		msg = m
+	case "file":
+		var m DocumentMessage
+		err = json.Unmarshal(data, &m)
+		msg = m
+	case "template":
+		var m TemplateMessage
+		err = json.Unmarshal(data, &m)
+		msg = m
+	case "flow":
+		var m FlowMessage
+		err = json.Unmarshal(data, &m)
+		msg = m
+	case "cta_url":
+		var m CTAURLMessage
+		err = json.Unmarshal(data, &m)
+		msg = m
+	case "location_request_message":
+		var m LocationRequestMessage
+		err = json.Unmarshal(data, &m)
+		msg = m
+	case "product_details":
+		switch head.SubType {
+		case "product":
+			var m ProductMessage
+			err = json.Unmarshal(data, &m)
+			msg = m
+		case "product_list":
+			var m MultiProductMessage
+			err = json.Unmarshal(data, &m)
+			msg = m
+		default:
+			return nil, merry.Errorf("unknown product_details subType %q", head.SubType)
+		}
+	case "list":
+		var m ListMessage
+		err = json.Unmarshal(data, &m)
+		msg = m
+	case "quick_reply":
+		switch head.Content.Type {
+		case "text":
+			var m QuickReplyText
+			err = json.Unmarshal(data, &m)
+			msg = m
+		case "image":
+			var m QuickReplyImage
+			err = json.Unmarshal(data, &m)
+			msg = m
+		case "video":
+			var m QuickReplyVideo
+			err = json.Unmarshal(data, &m)
+			msg = m
+		case "document", "file":
+			var m QuickReplyDocument
+			err = json.Unmarshal(data, &m)
+			msg = m
+		default:
+			return nil, merry.Errorf("unknown quick_reply content type %q", head.Content.Type)
+		}
+	default:
+		return nil, merry.Errorf("unknown message type %q", head.Type)
+	}
+
+	if err != nil {
+		return nil, merry.Errorf("failed to parse %s message: %s", head.Type, err)
+	}
+	return msg, nil
+}
+
+// DecodeOutbound decodes the values of a send request, as created by
+// OutboundMessage, into the defaults and the typed message
+func DecodeOutbound(values url.Values) (OutboundMessage, Outbound, error) {
+	om := OutboundMessage{
+		Channel:        values.Get("channel"),
+		Destination:    values.Get("destination"),
+		Source:         values.Get("source"),
+		SourceName:     values.Get("src.name"),
+		DisablePreview: values.Get("disablePreview") == "true",
+	}
+
+	if tmpl := values.Get("template"); tmpl != "" {
+		var m TemplateMessage
+		if err := json.Unmarshal([]byte(tmpl), &m); err != nil {
+			return om, nil, merry.Errorf("failed to parse template: %s", err)
+		}
+		return om, m, nil
+	}
+
+	msg, err := UnmarshalOutbound([]byte(values.Get("message")))
+	return om, msg, err
+}
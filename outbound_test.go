@@ -0,0 +1,144 @@
+package wabaapi
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOutboundRoundTrip(t *testing.T) {
+	om := &OutboundMessage{
+		Channel:     "whatsapp",
+		Destination: "34600000001",
+		Source:      "15555555555",
+		SourceName:  "Our Company",
+	}
+
+	msgs := []Outbound{
+		TextMessage{Text: "hello"},
+		ImageMessage{OriginalURL: "https://example.com/a.jpg", PreviewURL: "https://example.com/a_small.jpg"},
+		AudioMessage{URL: "https://example.com/a.ogg"},
+		VideoMessage{URL: "https://example.com/a.mp4", Caption: "a video"},
+		DocumentMessage{URL: "https://example.com/a.pdf", Filename: "a.pdf"},
+		ImageMessage{ID: "media-1", Caption: "by id"},
+		AudioMessage{ID: "media-2"},
+		VideoMessage{ID: "media-3", Caption: "a video"},
+		DocumentMessage{ID: "media-4", Filename: "a.pdf"},
+		TemplateMessage{ID: "tmpl-1", Params: []string{"Ana"}},
+		ListMessage{Title: "title", Body: "body", GlobalButton: "menu", Items: []ListItem{
+			{Title: "section", Options: []ListItemOption{{Title: "one", Description: "first", PostbackText: "1"}}},
+		}},
+		QuickReplyText{MsgID: "qr1", Header: "header", Text: "pick one", Options: []QuickReplyOption{"yes", "no"}},
+		QuickReplyImage{MsgID: "qr2", URL: "https://example.com/a.jpg", Text: "pick one", Options: []QuickReplyOption{"yes"}},
+		QuickReplyVideo{MsgID: "qr3", URL: "https://example.com/a.mp4", Text: "pick one", Options: []QuickReplyOption{"yes"}},
+		QuickReplyDocument{MsgID: "qr4", URL: "https://example.com/a.pdf", Filename: "a.pdf", Options: []QuickReplyOption{"yes"}},
+		ProductMessage{CatalogID: "cat", ProductID: "sku-1", Body: "our best seller"},
+		CTAURLMessage{Header: HeaderImage("https://example.com/a.jpg"), Body: "track your order", DisplayText: "Track", URL: "https://example.com/track"},
+		LocationRequestMessage{Body: "where should we deliver?"},
+		FlowMessage{Body: "book an appointment", FlowID: "1234", FlowCTA: "Book", FlowToken: "tok-1",
+			FlowAction: FlowNavigate, Screen: "APPOINTMENT", Data: map[string]interface{}{"name": "Ana"}},
+		FlowMessage{Body: "book an appointment", FlowID: "1234", FlowCTA: "Book", FlowAction: FlowDataExchange},
+		MultiProductMessage{CatalogID: "cat", Header: "Summer", Body: "new arrivals", Footer: "free shipping", Sections: []ProductSection{
+			{Title: "Shirts", ProductIDs: []string{"sku-1", "sku-2"}}, {Title: "Shorts", ProductIDs: []string{"sku-3"}},
+		}},
+	}
+
+	for _, msg := range msgs {
+		values, err := om.Build(msg)
+		require.NoError(t, err, msg.MessageType())
+
+		decodedOM, decoded, err := DecodeOutbound(values)
+		require.NoError(t, err, msg.MessageType())
+		assert.Equal(t, msg, decoded)
+		assert.Equal(t, om.Destination, decodedOM.Destination)
+
+		data, err := json.Marshal(msg)
+		require.NoError(t, err)
+		unmarshaled, err := UnmarshalOutbound(data)
+		require.NoError(t, err, string(data))
+		assert.Equal(t, msg, unmarshaled)
+	}
+}
+
+func TestQuickReplyMarshal(t *testing.T) {
+	val, err := json.Marshal(QuickReplyText{MsgID: "qr1", Text: "pick one", Options: []QuickReplyOption{"yes"}})
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"type":"quick_reply","msgid":"qr1","content":{"type":"text","text":"pick one","caption":""},
+		"options":[{"type":"text","title":"yes"}]}`, string(val))
+
+	var qr QuickReplyText
+	assert.NoError(t, json.Unmarshal([]byte(`{"msgid":"qr1","content":{"type":"text","text":"pick one"},"options":["yes",{"title":"no"}]}`), &qr))
+	assert.Equal(t, []QuickReplyOption{"yes", "no"}, qr.Options)
+}
+
+func TestQuickReplyOptionWireFormat(t *testing.T) {
+	om := &OutboundMessage{Channel: "whatsapp", Destination: "34600000001", Source: "15555555555", SourceName: "Our Company"}
+	values, err := om.QuickReplyText(QuickReplyText{MsgID: "qr1", Text: "pick one", Options: []QuickReplyOption{"yes"}})
+	require.NoError(t, err)
+
+	// Gupshup reads the button text from the title key of the options
+	var sent struct {
+		Options []map[string]string `json:"options"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(values.Get("message")), &sent))
+	assert.Equal(t, []map[string]string{{"type": "text", "title": "yes"}}, sent.Options)
+
+	var qr QuickReplyText
+	require.NoError(t, json.Unmarshal([]byte(`{"msgid":"qr1","content":{"type":"text","text":"pick one"},"options":[{"type":"text","text":"old"}]}`), &qr))
+	assert.Equal(t, []QuickReplyOption{"old"}, qr.Options)
+}
+
+func TestUnmarshalOutboundOldQuickReplyOptions(t *testing.T) {
+	msg, err := UnmarshalOutbound([]byte(`{"type":"quick_reply","msgid":"qr1","content":{"type":"image","url":"https://example.com/a.jpg","text":"pick one"},
+		"options":[{"type":"text","text":"yes"},{"type":"text","text":"no"}]}`))
+	require.NoError(t, err)
+	assert.Equal(t, QuickReplyImage{MsgID: "qr1", URL: "https://example.com/a.jpg", Text: "pick one", Options: []QuickReplyOption{"yes", "no"}}, msg)
+}
+
+func TestListMessageValidateReceiver(t *testing.T) {
+	om := &OutboundMessage{Channel: "whatsapp", Destination: "34600000001", Source: "15555555555", SourceName: "Our Company"}
+	_, err := om.Build(ListMessage{Title: "title"})
+	assert.Error(t, err, "list values are validated")
+	_, err = om.Build(&ListMessage{Title: "title"})
+	assert.Error(t, err, "list pointers are validated")
+}
+
+func TestBuildValidates(t *testing.T) {
+	om := &OutboundMessage{Channel: "whatsapp", Destination: "34600000001", Source: "15555555555", SourceName: "Our Company"}
+	_, err := om.Build(TextMessage{})
+	assert.Error(t, err)
+	_, err = om.Build(TemplateMessage{})
+	assert.Error(t, err)
+
+	om.DoNotValidate = true
+	_, err = om.Build(TextMessage{})
+	assert.NoError(t, err)
+}
+
+func TestMediaByID(t *testing.T) {
+	om := &OutboundMessage{Channel: "whatsapp", Destination: "34600000001", Source: "15555555555", SourceName: "Our Company"}
+
+	values, err := om.ImageID("media-1", "a photo")
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"type":"image","id":"media-1","caption":"a photo"}`, values.Get("message"))
+
+	_, err = om.Build(ImageMessage{ID: "media-1", OriginalURL: "https://example.com/a.jpg"})
+	assert.Error(t, err)
+	_, err = om.Build(ImageMessage{})
+	assert.Error(t, err)
+	_, err = om.AudioID("")
+	assert.Error(t, err)
+	_, err = om.Build(VideoMessage{ID: "media-3", URL: "https://example.com/a.mp4"})
+	assert.Error(t, err)
+	_, err = om.Document("not a url", "a.pdf")
+	assert.Error(t, err)
+
+	u, id := mediaRef(&GCSMediaServer{}, "/media/a.pdf")
+	assert.Equal(t, "/media/a.pdf", u)
+	assert.Empty(t, id)
+	u, id = mediaRef(&GupshupMediaServer{}, "3cd5d5f6-2a4b-4c5d-9e1f-0a1b2c3d4e5f")
+	assert.Empty(t, u)
+	assert.Equal(t, "3cd5d5f6-2a4b-4c5d-9e1f-0a1b2c3d4e5f", id)
+}
@@ -0,0 +1,369 @@
+package wabaapi
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/ansel1/merry"
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/go-ozzo/ozzo-validation/v4/is"
+)
+
+// DefaultPartnerURL is the base URL of Gupshup's partner API
+const DefaultPartnerURL = "https://partner.gupshup.io/partner"
+
+// Subscription modes, the callbacks sent to a subscription URL
+const (
+	ModeMessage   = "MESSAGE"
+	ModeEnqueued  = "ENQUEUED"
+	ModeSent      = "SENT"
+	ModeDelivered = "DELIVERED"
+	ModeRead      = "READ"
+	ModeFailed    = "FAILED"
+	ModeDeleted   = "DELETED"
+	ModeAccount   = "ACCOUNT"
+	ModeBilling   = "BILLING"
+	ModeTemplate  = "TEMPLATE"
+	ModeFlows     = "FLOWS_MESSAGE"
+	ModeOthers    = "OTHERS"
+	ModeAll       = "ALL"
+)
+
+// DefaultModes are the callbacks handled by InboundDecoder
+var DefaultModes = []string{ModeMessage, ModeEnqueued, ModeSent, ModeDelivered, ModeRead, ModeFailed, ModeAccount, ModeBilling, ModeOthers}
+
+// PartnerClient manages the apps of a Gupshup partner account. Log in with
+// Login or set Token to a partner token. App endpoints are called with the
+// app token, fetched with AppToken and cached.
+type PartnerClient struct {
+	// Token is the partner token returned by Login
+	Token      string
+	BaseURL    string
+	HTTPClient *http.Client
+
+	mu     sync.Mutex
+	tokens map[string]string
+}
+
+// PartnerApp is an app of the partner account
+type PartnerApp struct {
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	Phone      string `json:"phone"`
+	CustomerID string `json:"customerId"`
+	Live       bool   `json:"live"`
+	Stopped    bool   `json:"stopped"`
+	Healthy    bool   `json:"healthy"`
+}
+
+// Subscription sends the callbacks of Modes to URL
+type Subscription struct {
+	ID  string `json:"id,omitempty"`
+	Tag string `json:"tag"`
+	URL string `json:"url"`
+	// Modes are the subscribed callbacks, like ModeMessage or ModeSent
+	Modes []string `json:"modes"`
+	// Version is the callback format, InboundMessage decodes version 2
+	Version int  `json:"version"`
+	Active  bool `json:"active"`
+}
+
+func (s Subscription) Validate() error {
+	return validation.ValidateStruct(&s,
+		validation.Field(&s.Tag, validation.Required),
+		validation.Field(&s.URL, validation.Required, is.URL),
+		validation.Field(&s.Modes, validation.Required),
+		validation.Field(&s.Version, validation.In(0, 2, 3)),
+	)
+}
+
+// subscription is a Subscription as sent by Gupshup, with the modes comma separated
+type subscription struct {
+	ID      string `json:"id"`
+	Tag     string `json:"tag"`
+	URL     string `json:"url"`
+	Modes   string `json:"modes"`
+	Version int    `json:"version"`
+	Active  bool   `json:"active"`
+}
+
+func (s subscription) subscription() Subscription {
+	sub := Subscription{ID: s.ID, Tag: s.Tag, URL: s.URL, Version: s.Version, Active: s.Active}
+	if s.Modes != "" {
+		sub.Modes = strings.Split(s.Modes, ",")
+	}
+	return sub
+}
+
+func (pc *PartnerClient) baseURL() string {
+	if pc.BaseURL == "" {
+		return DefaultPartnerURL
+	}
+	return strings.TrimSuffix(pc.BaseURL, "/")
+}
+
+func (pc *PartnerClient) httpClient() *http.Client {
+	if pc.HTTPClient == nil {
+		return &http.Client{Timeout: DefaultTimeout}
+	}
+	return pc.HTTPClient
+}
+
+// do sends a request authorized with token, form encoding values in the body
+func (pc *PartnerClient) do(ctx context.Context, method string, endpoint string, token string, values url.Values, out interface{}) (int, error) {
+	var body io.Reader
+	if values != nil {
+		body = strings.NewReader(values.Encode())
+	}
+	req, err := http.NewRequestWithContext(ctx, method, pc.baseURL()+endpoint, body)
+	if err != nil {
+		return 0, merry.Wrap(err)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", token)
+	}
+	if values != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	return roundTrip(pc.httpClient(), req, out)
+}
+
+func (pc *PartnerClient) partnerToken() (string, error) {
+	if pc.Token == "" {
+		return "", merry.New("partner token not configured, call Login")
+	}
+	return pc.Token, nil
+}
+
+// Login gets a partner token with the partner account credentials and sets Token
+func (pc *PartnerClient) Login(ctx context.Context, email string, password string) error {
+	var resp struct {
+		Token string `json:"token"`
+	}
+	values := url.Values{"email": {email}, "password": {password}}
+	if _, err := pc.do(ctx, http.MethodPost, "/account/login", "", values, &resp); err != nil {
+		return err
+	}
+	if resp.Token == "" {
+		return merry.New("login returned no token")
+	}
+	pc.Token = resp.Token
+	return nil
+}
+
+// Apps lists the apps of the partner account
+func (pc *PartnerClient) Apps(ctx context.Context) ([]PartnerApp, error) {
+	token, err := pc.partnerToken()
+	if err != nil {
+		return nil, err
+	}
+	var resp struct {
+		Status string       `json:"status"`
+		Apps   []PartnerApp `json:"partnerAppsList"`
+	}
+	if _, err := pc.do(ctx, http.MethodGet, "/account/api/partnerApps", token, nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Apps, nil
+}
+
+// App returns the app of the partner account with name
+func (pc *PartnerClient) App(ctx context.Context, name string) (PartnerApp, error) {
+	apps, err := pc.Apps(ctx)
+	if err != nil {
+		return PartnerApp{}, err
+	}
+	for _, app := range apps {
+		if app.Name == name {
+			return app, nil
+		}
+	}
+	return PartnerApp{}, ErrUnknownApp.Append(name).WithHTTPCode(http.StatusNotFound)
+}
+
+// AppToken returns the access token of the app with appID, used by the app
+// endpoints. Tokens are cached, the first call fetches it, and dropped when
+// an app endpoint rejects them
+func (pc *PartnerClient) AppToken(ctx context.Context, appID string) (string, error) {
+	pc.mu.Lock()
+	token, ok := pc.tokens[appID]
+	pc.mu.Unlock()
+	if ok {
+		return token, nil
+	}
+
+	partner, err := pc.partnerToken()
+	if err != nil {
+		return "", err
+	}
+	var resp struct {
+		Status string `json:"status"`
+		Token  struct {
+			Token string `json:"token"`
+		} `json:"token"`
+	}
+	if _, err := pc.do(ctx, http.MethodGet, "/app/"+url.PathEscape(appID)+"/token", partner, nil, &resp); err != nil {
+		return "", err
+	}
+	if resp.Token.Token == "" {
+		return "", merry.Errorf("no token returned for app %s", appID)
+	}
+
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	if pc.tokens == nil {
+		pc.tokens = map[string]string{}
+	}
+	pc.tokens[appID] = resp.Token.Token
+	return resp.Token.Token, nil
+}
+
+// forgetAppToken removes token from the cache unless it was already replaced
+func (pc *PartnerClient) forgetAppToken(appID string, token string) {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	if pc.tokens[appID] == token {
+		delete(pc.tokens, appID)
+	}
+}
+
+// appDo calls an app endpoint with the app token. A 401 response clears the
+// cached token and the call is retried once with a new one
+func (pc *PartnerClient) appDo(ctx context.Context, method string, appID string, endpoint string, values url.Values, out interface{}) error {
+	for retry := 0; ; retry++ {
+		token, err := pc.AppToken(ctx, appID)
+		if err != nil {
+			return err
+		}
+		_, err = pc.do(ctx, method, "/app/"+url.PathEscape(appID)+endpoint, token, values, out)
+		if merry.HTTPCode(err) != http.StatusUnauthorized {
+			return err
+		}
+		pc.forgetAppToken(appID, token)
+		if retry > 0 {
+			return err
+		}
+	}
+}
+
+// SetCallbackURL sets the URL receiving the callbacks of the app, the URL of
+// its WebhookHandler
+func (pc *PartnerClient) SetCallbackURL(ctx context.Context, appID string, callbackURL string) error {
+	if err := validation.Validate(callbackURL, validation.Required, is.URL); err != nil {
+		return merry.Prepend(err, "callback url")
+	}
+	return pc.appDo(ctx, http.MethodPut, appID, "/callbackUrl", url.Values{"callbackUrl": {callbackURL}}, nil)
+}
+
+// Subscriptions lists the subscriptions of the app
+func (pc *PartnerClient) Subscriptions(ctx context.Context, appID string) ([]Subscription, error) {
+	var resp struct {
+		Status        string         `json:"status"`
+		Subscriptions []subscription `json:"subscriptions"`
+	}
+	if err := pc.appDo(ctx, http.MethodGet, appID, "/subscription", nil, &resp); err != nil {
+		return nil, err
+	}
+	subs := make([]Subscription, len(resp.Subscriptions))
+	for i, s := range resp.Subscriptions {
+		subs[i] = s.subscription()
+	}
+	return subs, nil
+}
+
+// Subscribe adds a subscription to the app and returns it with its ID.
+// Version defaults to 2
+func (pc *PartnerClient) Subscribe(ctx context.Context, appID string, sub Subscription) (Subscription, error) {
+	if err := sub.Validate(); err != nil {
+		return Subscription{}, err
+	}
+	if sub.Version == 0 {
+		sub.Version = 2
+	}
+	values := url.Values{
+		"tag":     {sub.Tag},
+		"url":     {sub.URL},
+		"modes":   {strings.Join(sub.Modes, ",")},
+		"version": {strconv.Itoa(sub.Version)},
+	}
+	var resp struct {
+		Status       string       `json:"status"`
+		Subscription subscription `json:"subscription"`
+	}
+	if err := pc.appDo(ctx, http.MethodPost, appID, "/subscription", values, &resp); err != nil {
+		return Subscription{}, err
+	}
+	return resp.Subscription.subscription(), nil
+}
+
+// Unsubscribe deletes the subscription with id of the app
+func (pc *PartnerClient) Unsubscribe(ctx context.Context, appID string, id string) error {
+	return pc.appDo(ctx, http.MethodDelete, appID, "/subscription/"+url.PathEscape(id), nil, nil)
+}
+
+// SetOptinRequired sets whether users must opt in before the app can send
+// them messages
+func (pc *PartnerClient) SetOptinRequired(ctx context.Context, appID string, required bool) error {
+	values := url.Values{"isOptinRequired": {strconv.FormatBool(required)}}
+	return pc.appDo(ctx, http.MethodPut, appID, "/appPreference", values, nil)
+}
+
+// AppSettings are the settings applied by Configure
+type AppSettings struct {
+	// CallbackURL is the URL of the WebhookHandler
+	CallbackURL string
+	// Tag names the subscription to CallbackURL, the existing subscriptions
+	// with the same tag are deleted once it is created. Defaults to "wabaapi"
+	Tag string
+	// Modes default to DefaultModes
+	Modes         []string
+	OptinRequired bool
+}
+
+// Configure points the app at CallbackURL, subscribing it to Modes in the
+// version 2 format, and sets its opt-in requirement
+func (pc *PartnerClient) Configure(ctx context.Context, appID string, settings AppSettings) error {
+	if settings.Tag == "" {
+		settings.Tag = "wabaapi"
+	}
+	if len(settings.Modes) == 0 {
+		settings.Modes = DefaultModes
+	}
+	sub := Subscription{Tag: settings.Tag, URL: settings.CallbackURL, Modes: settings.Modes, Version: 2}
+	if err := sub.Validate(); err != nil {
+		return err
+	}
+
+	if err := pc.SetCallbackURL(ctx, appID, settings.CallbackURL); err != nil {
+		return merry.Prepend(err, "set callback url")
+	}
+
+	// The new subscription is created before deleting the old ones, so the
+	// app keeps its callbacks when it fails
+	subs, err := pc.Subscriptions(ctx, appID)
+	if err != nil {
+		return merry.Prepend(err, "list subscriptions")
+	}
+	created, err := pc.Subscribe(ctx, appID, sub)
+	if err != nil {
+		return merry.Prepend(err, "subscribe")
+	}
+	for _, s := range subs {
+		if s.Tag != settings.Tag || s.ID == created.ID {
+			continue
+		}
+		if err := pc.Unsubscribe(ctx, appID, s.ID); err != nil {
+			return merry.Prependf(err, "delete subscription %s", s.ID)
+		}
+	}
+
+	if err := pc.SetOptinRequired(ctx, appID, settings.OptinRequired); err != nil {
+		return merry.Prepend(err, "set opt-in")
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+// Package phone parses and normalizes phone numbers to the format Gupshup
+// expects: E.164 digits without the leading '+'.
+package phone
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/ansel1/merry"
+	"github.com/nyaruka/phonenumbers"
+)
+
+// ErrInvalid is the error returned for numbers that cannot be parsed or that
+// are not valid for their country
+var ErrInvalid = merry.New("invalid phone number")
+
+// Normalize parses number and returns it as E.164 digits without the leading '+'.
+// Numbers in national format are read as numbers of defaultRegion, an ISO 3166
+// alpha-2 code like "ES". With an empty defaultRegion the number must include
+// its country code, with or without '+', as Gupshup sends them. Those are
+// also accepted with a defaultRegion when they are not valid national numbers.
+func Normalize(number string, defaultRegion string) (string, error) {
+	num, err := Parse(number, defaultRegion)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimPrefix(phonenumbers.Format(num, phonenumbers.E164), "+"), nil
+}
+
+// Parse parses number like Normalize and checks it is valid for its country.
+// With a defaultRegion, numbers that are not valid in it are tried as
+// international numbers without '+', as Gupshup sends them
+func Parse(number string, defaultRegion string) (*phonenumbers.PhoneNumber, error) {
+	number = strings.TrimSpace(number)
+	if number == "" {
+		return nil, ErrInvalid.Append("empty number")
+	}
+
+	region := strings.ToUpper(defaultRegion)
+	if region == "" && !strings.HasPrefix(number, "+") {
+		number = "+" + strings.TrimPrefix(number, "00")
+	}
+
+	num, err := parseValid(number, region)
+	if err != nil && region != "" && !strings.HasPrefix(number, "+") {
+		if intl, intlErr := parseValid("+"+number, ""); intlErr == nil {
+			return intl, nil
+		}
+	}
+	return num, err
+}
+
+func parseValid(number string, region string) (*phonenumbers.PhoneNumber, error) {
+	num, err := phonenumbers.Parse(number, region)
+	if err != nil {
+		return nil, ErrInvalid.Append(err.Error())
+	}
+
+	if !phonenumbers.IsValidNumber(num) {
+		return nil, ErrInvalid.Appendf("%s is not valid for region %s", number, phonenumbers.GetRegionCodeForNumber(num))
+	}
+	return num, nil
+}
+
+// Region returns the ISO 3166 alpha-2 region for a country calling code like "34"
+// or "+34", or an empty string if the code is unknown
+func Region(dialCode string) string {
+	code, err := strconv.Atoi(strings.TrimPrefix(strings.TrimSpace(dialCode), "+"))
+	if err != nil {
+		return ""
+	}
+	region := phonenumbers.GetRegionCodeForCountryCode(code)
+	if region == phonenumbers.UNKNOWN_REGION {
+		return ""
+	}
+	return region
+}
+
+// RegionOf returns the ISO 3166 alpha-2 region of a number with country code,
+// as Gupshup sends them. Numbers that are not valid get the main region of their
+// country code, numbers that cannot be parsed an empty string
+func RegionOf(number string) string {
+	number = strings.TrimPrefix(strings.TrimSpace(number), "+")
+	num, err := phonenumbers.Parse("+"+strings.TrimPrefix(number, "00"), "")
+	if err != nil {
+		return ""
+	}
+	if region := phonenumbers.GetRegionCodeForNumber(num); region != "" && region != phonenumbers.UNKNOWN_REGION {
+		return region
+	}
+	return Region(strconv.Itoa(int(num.GetCountryCode())))
+}
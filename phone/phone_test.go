@@ -0,0 +1,58 @@
+package phone
+
+import (
+	"testing"
+
+	"github.com/ansel1/merry"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		number string
+		region string
+		want   string
+	}{
+		{"+34 600 00 00 01", "", "34600000001"},
+		{"34600000001", "", "34600000001"},
+		{"600 000 001", "ES", "34600000001"},
+		{"(202) 555-0143", "us", "12025550143"},
+		{"098765 43210", "IN", "919876543210"},
+		{"0034600000001", "", "34600000001"},
+		{"447911123456", "ES", "447911123456"},
+		{"+44 7911 123456", "ES", "447911123456"},
+		{"12025550143", "ES", "12025550143"},
+		{"919876543210", "GB", "919876543210"},
+		{"34600000001", "ES", "34600000001"},
+	}
+
+	for _, tt := range tests {
+		got, err := Normalize(tt.number, tt.region)
+		if assert.NoError(t, err, tt.number) {
+			assert.Equal(t, tt.want, got, tt.number)
+		}
+	}
+}
+
+func TestNormalizeInvalid(t *testing.T) {
+	for _, number := range []string{"", "not a phone", "+1234567890", "600 000 001", "+34 100 000 000"} {
+		_, err := Normalize(number, "")
+		assert.True(t, merry.Is(err, ErrInvalid), number)
+	}
+	_, err := Normalize("123", "ES")
+	assert.True(t, merry.Is(err, ErrInvalid))
+}
+
+func TestRegion(t *testing.T) {
+	assert.Equal(t, "ES", Region("34"))
+	assert.Equal(t, "IN", Region("+91"))
+	assert.Equal(t, "", Region("x"))
+}
+
+func TestRegionOf(t *testing.T) {
+	assert.Equal(t, "ES", RegionOf("34600000001"))
+	assert.Equal(t, "GB", RegionOf("+447700900123"))
+	assert.Equal(t, "US", RegionOf("12025550123"))
+	assert.Equal(t, "ES", RegionOf("34100000000"))
+	assert.Equal(t, "", RegionOf("not a phone"))
+}
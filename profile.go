@@ -0,0 +1,208 @@
+package wabaapi
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"strings"
+
+	"github.com/ansel1/merry"
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/go-ozzo/ozzo-validation/v4/is"
+)
+
+// Business verticals of the profile
+const (
+	VerticalOther        = "OTHER"
+	VerticalAuto         = "AUTO"
+	VerticalBeauty       = "BEAUTY"
+	VerticalApparel      = "APPAREL"
+	VerticalEducation    = "EDU"
+	VerticalEntertain    = "ENTERTAIN"
+	VerticalEventPlan    = "EVENT_PLAN"
+	VerticalFinance      = "FINANCE"
+	VerticalGrocery      = "GROCERY"
+	VerticalGovernment   = "GOVT"
+	VerticalHotel        = "HOTEL"
+	VerticalHealth       = "HEALTH"
+	VerticalNonProfit    = "NONPROFIT"
+	VerticalProfServices = "PROF_SERVICES"
+	VerticalRetail       = "RETAIL"
+	VerticalTravel       = "TRAVEL"
+	VerticalRestaurant   = "RESTAURANT"
+)
+
+var verticals = []interface{}{
+	VerticalOther, VerticalAuto, VerticalBeauty, VerticalApparel, VerticalEducation, VerticalEntertain,
+	VerticalEventPlan, VerticalFinance, VerticalGrocery, VerticalGovernment, VerticalHotel, VerticalHealth,
+	VerticalNonProfit, VerticalProfServices, VerticalRetail, VerticalTravel, VerticalRestaurant,
+}
+
+// WhatsApp limits of the profile photo, in pixels
+const (
+	MinProfilePhotoDimension = 192
+	MaxProfilePhotoDimension = 640
+)
+
+// ErrProfilePhotoDimensions is returned when the profile photo is too small or too large
+var ErrProfilePhotoDimensions = merry.New("profile photo dimensions not supported")
+
+// BusinessProfile is the WhatsApp business profile of an app. Empty fields
+// are left unchanged by UpdateBusinessProfile
+type BusinessProfile struct {
+	// About is the text under the business name
+	About       string   `json:"about,omitempty"`
+	Description string   `json:"description,omitempty"`
+	Address     string   `json:"address,omitempty"`
+	Email       string   `json:"email,omitempty"`
+	Websites    []string `json:"websites,omitempty"`
+	// Vertical is the industry of the business, like VerticalRetail
+	Vertical string `json:"vertical,omitempty"`
+	// PhotoURL is the URL of the current profile photo, it is read only
+	PhotoURL string `json:"profile_picture_url,omitempty"`
+}
+
+func (p BusinessProfile) Validate() error {
+	return validation.ValidateStruct(&p,
+		validation.Field(&p.About, validation.RuneLength(0, 139)),
+		validation.Field(&p.Description, validation.RuneLength(0, 512)),
+		validation.Field(&p.Address, validation.RuneLength(0, 256)),
+		validation.Field(&p.Email, validation.RuneLength(0, 128), is.EmailFormat),
+		validation.Field(&p.Websites, validation.Length(0, 2), validation.Each(validation.Required, validation.RuneLength(1, 256), is.URL)),
+		validation.Field(&p.Vertical, validation.In(verticals...)),
+	)
+}
+
+// BusinessProfile returns the business profile of app
+func (c *Client) BusinessProfile(ctx context.Context, app string) (BusinessProfile, error) {
+	var resp struct {
+		Status  string          `json:"status"`
+		Profile BusinessProfile `json:"profile"`
+	}
+	if _, err := c.do(ctx, http.MethodGet, "/app/"+url.PathEscape(app)+"/business/profile", nil, "", &resp); err != nil {
+		return BusinessProfile{}, err
+	}
+	return resp.Profile, nil
+}
+
+// UpdateBusinessProfile updates the non empty fields of the business profile of app
+func (c *Client) UpdateBusinessProfile(ctx context.Context, app string, profile BusinessProfile) error {
+	if err := profile.Validate(); err != nil {
+		return err
+	}
+
+	values := url.Values{}
+	set := func(key string, value string) {
+		if value != "" {
+			values.Set(key, value)
+		}
+	}
+	set("about", profile.About)
+	set("description", profile.Description)
+	set("address", profile.Address)
+	set("email", profile.Email)
+	set("vertical", profile.Vertical)
+	for _, website := range profile.Websites {
+		values.Add("websites", website)
+	}
+	if len(values) == 0 {
+		return merry.New("business profile has no fields to update")
+	}
+
+	_, err := c.do(ctx, http.MethodPut, "/app/"+url.PathEscape(app)+"/business/profile",
+		strings.NewReader(values.Encode()), "application/x-www-form-urlencoded", nil)
+	return err
+}
+
+// ValidateProfilePhoto checks data is a JPEG or PNG within the image size limit
+// and between MinProfilePhotoDimension and MaxProfilePhotoDimension pixels
+// wide and high, and returns its content type
+func ValidateProfilePhoto(data []byte) (string, error) {
+	media := MediaServerMedia{Reader: ioutil.NopCloser(bytes.NewReader(data))}
+	if err := media.Validate(MediaImage); err != nil {
+		return "", err
+	}
+
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return "", merry.Errorf("failed to decode profile photo: %s", err)
+	}
+	if cfg.Width < MinProfilePhotoDimension || cfg.Height < MinProfilePhotoDimension ||
+		cfg.Width > MaxProfilePhotoDimension || cfg.Height > MaxProfilePhotoDimension {
+		return "", ErrProfilePhotoDimensions.Appendf("%dx%d is not between %dx%d and %dx%d", cfg.Width, cfg.Height,
+			MinProfilePhotoDimension, MinProfilePhotoDimension, MaxProfilePhotoDimension, MaxProfilePhotoDimension)
+	}
+	return media.ContentType, nil
+}
+
+// SetProfilePhoto validates the image read from r with ValidateProfilePhoto
+// and uploads it as the profile photo of app
+func (c *Client) SetProfilePhoto(ctx context.Context, app string, r io.Reader) error {
+	data, err := ioutil.ReadAll(io.LimitReader(r, MediaRules[MediaImage].MaxSize+1))
+	if err != nil {
+		return merry.Wrap(err)
+	}
+	ctype, err := ValidateProfilePhoto(data)
+	if err != nil {
+		return err
+	}
+	return c.putProfilePhoto(ctx, app, data, ctype)
+}
+
+// SetProfilePhotoMedia validates media with ValidateProfilePhoto, stores it in
+// its media server and uploads it as the profile photo of app. It returns the
+// media server URL of the photo. The media reader is closed
+func (c *Client) SetProfilePhotoMedia(ctx context.Context, app string, media *MediaServerMedia) (string, error) {
+	if media.Server == nil {
+		return "", merry.New("media server not specified")
+	}
+	if media.Reader == nil {
+		return "", merry.New("media reader not specified")
+	}
+	data, err := ioutil.ReadAll(io.LimitReader(media.Reader, MediaRules[MediaImage].MaxSize+1))
+	media.Reader.Close()
+	if err != nil {
+		return "", merry.Wrap(err)
+	}
+	ctype, err := ValidateProfilePhoto(data)
+	if err != nil {
+		return "", err
+	}
+
+	stored := MediaServerMedia{Server: media.Server, Reader: ioutil.NopCloser(bytes.NewReader(data)), ContentType: ctype}
+	u, err := stored.PutFile(ctx)
+	if err != nil {
+		return "", err
+	}
+	if err := c.putProfilePhoto(ctx, app, data, ctype); err != nil {
+		return "", err
+	}
+	return u, nil
+}
+
+func (c *Client) putProfilePhoto(ctx context.Context, app string, data []byte, ctype string) error {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	header := textproto.MIMEHeader{}
+	header.Set("Content-Disposition", `form-data; name="image"; filename="profile"`)
+	header.Set("Content-Type", ctype)
+	part, err := mw.CreatePart(header)
+	if err != nil {
+		return merry.Wrap(err)
+	}
+	if _, err := part.Write(data); err != nil {
+		return merry.Wrap(err)
+	}
+	if err := mw.Close(); err != nil {
+		return merry.Wrap(err)
+	}
+
+	_, err = c.do(ctx, http.MethodPut, "/app/"+url.PathEscape(app)+"/business/profile/photo", &body, mw.FormDataContentType(), nil)
+	return err
+}
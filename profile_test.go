@@ -0,0 +1,72 @@
+package wabaapi
+
+import (
+	"bytes"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"testing"
+
+	"github.com/ansel1/merry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateProfilePhoto(t *testing.T) {
+	encode := func(w, h int, enc func(*bytes.Buffer, image.Image) error) []byte {
+		var buf bytes.Buffer
+		require.NoError(t, enc(&buf, image.NewRGBA(image.Rect(0, 0, w, h))))
+		return buf.Bytes()
+	}
+	pngEnc := func(b *bytes.Buffer, img image.Image) error { return png.Encode(b, img) }
+	jpegEnc := func(b *bytes.Buffer, img image.Image) error { return jpeg.Encode(b, img, nil) }
+	gifEnc := func(b *bytes.Buffer, img image.Image) error { return gif.Encode(b, img, nil) }
+
+	ctype, err := ValidateProfilePhoto(encode(640, 640, pngEnc))
+	require.NoError(t, err)
+	assert.Equal(t, "image/png", ctype)
+
+	ctype, err = ValidateProfilePhoto(encode(192, 300, jpegEnc))
+	require.NoError(t, err)
+	assert.Equal(t, "image/jpeg", ctype)
+
+	_, err = ValidateProfilePhoto(encode(100, 300, pngEnc))
+	assert.True(t, merry.Is(err, ErrProfilePhotoDimensions))
+	assert.Contains(t, err.Error(), "100x300")
+
+	_, err = ValidateProfilePhoto(encode(641, 300, jpegEnc))
+	assert.True(t, merry.Is(err, ErrProfilePhotoDimensions))
+
+	_, err = ValidateProfilePhoto(encode(300, 300, gifEnc))
+	assert.True(t, merry.Is(err, ErrMediaType))
+
+	_, err = ValidateProfilePhoto(append([]byte("\x89PNG\r\n\x1a\n"), make([]byte, 100)...))
+	assert.Error(t, err)
+}
+
+func TestBusinessProfileValidate(t *testing.T) {
+	profile := BusinessProfile{
+		About:    "Fresh bread every morning",
+		Email:    "hello@example.com",
+		Websites: []string{"https://example.com", "https://shop.example.com"},
+		Vertical: VerticalRestaurant,
+	}
+	assert.NoError(t, profile.Validate())
+
+	invalid := profile
+	invalid.Email = "not an email"
+	assert.Error(t, invalid.Validate())
+
+	invalid = profile
+	invalid.Websites = append(invalid.Websites, "https://third.example.com")
+	assert.Error(t, invalid.Validate())
+
+	invalid = profile
+	invalid.Vertical = "BAKERY"
+	assert.Error(t, invalid.Validate())
+
+	invalid = profile
+	invalid.About = string(make([]rune, 140))
+	assert.Error(t, invalid.Validate())
+}
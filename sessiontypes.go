@@ -1,20 +1,23 @@
 package wabaapi
 
 import (
+	"context"
 	"encoding/json"
 	"net/url"
 
-	"github.com/ansel1/merry"
 	validation "github.com/go-ozzo/ozzo-validation/v4"
-	"github.com/go-ozzo/ozzo-validation/v4/is"
+	"github.com/panitaxx/gupshup-wabaapi/phone"
 )
 
 // OutboundMessage is the basic structure for creating reply messages.
 // Call this structure with the appropriate method to create a reply message.
-// Limited validation is performed on the structure
+// Limited validation is performed on the structure.
+// Destination may be in national format when DefaultRegion is set, it is
+// normalized to E.164 without '+' before sending
 type OutboundMessage struct {
 	Channel        string
 	Destination    string
+	DefaultRegion  string
 	Source         string
 	SourceName     string
 	DisablePreview bool
@@ -28,19 +31,30 @@ func (om *OutboundMessage) Validate() error {
 
 	return validation.ValidateStruct(om,
 		validation.Field(&om.Channel, validation.Required),
-		validation.Field(&om.Destination, validation.Required, is.E164),
+		validation.Field(&om.Destination, validation.Required, validation.By(om.validateDestination)),
 		validation.Field(&om.Source, validation.Required),
 		validation.Field(&om.SourceName, validation.Required),
 	)
 }
 
+func (om *OutboundMessage) validateDestination(value interface{}) error {
+	_, err := phone.Normalize(om.Destination, om.DefaultRegion)
+	return err
+}
+
 func (om *OutboundMessage) defaultValues() (url.Values, error) {
 	if err := om.Validate(); err != nil {
 		return nil, err
 	}
+
+	destination, err := phone.Normalize(om.Destination, om.DefaultRegion)
+	if err != nil {
+		destination = om.Destination
+	}
+
 	values := url.Values{}
 	values.Add("channel", om.Channel)
-	values.Add("destination", om.Destination)
+	values.Add("destination", destination)
 	values.Add("source", om.Source)
 	values.Add("src.name", om.SourceName)
 	values.Add("disablePreview", "true")
@@ -49,128 +63,135 @@ func (om *OutboundMessage) defaultValues() (url.Values, error) {
 
 //Text creates a text message
 func (om *OutboundMessage) Text(text string) (url.Values, error) {
-	values, err := om.defaultValues()
-	if err != nil {
-		return nil, err
-	}
-
-	if !om.DoNotValidate && text == "" {
-		return nil, merry.New("text cannot be empty")
-	}
-
-	msg := map[string]string{
-		"type": "text",
-		"text": text,
-	}
-	txt, _ := json.Marshal(msg)
-	values.Add("message", string(txt))
-	return values, nil
+	return om.Build(TextMessage{Text: text})
 }
 
 //Image creates an image message
 func (om *OutboundMessage) Image(originalURL string, previewURL string) (url.Values, error) {
-	values, err := om.defaultValues()
-	if err != nil {
-		return nil, err
-	}
+	return om.Build(ImageMessage{OriginalURL: originalURL, PreviewURL: previewURL})
+}
 
-	if !om.DoNotValidate {
+//ImageID creates an image message with a media uploaded with Client.UploadMedia
+func (om *OutboundMessage) ImageID(id string, caption string) (url.Values, error) {
+	return om.Build(ImageMessage{ID: id, Caption: caption})
+}
 
-		if err = validation.Validate(originalURL, validation.Required, is.URL); err != nil {
-			return nil, err
+//ImageMS uploads the original and the preview and creates an image message.
+//With a MediaIDServer original server, like GupshupMediaServer, WhatsApp
+//makes the preview itself: the preview is optional, it is not validated nor
+//uploaded and its reader, if any, is closed
+func (om *OutboundMessage) ImageMS(ctx context.Context, original MediaServerMedia, preview MediaServerMedia) (url.Values, error) {
+	if err := original.Validate(MediaImage); err != nil {
+		return nil, err
+	}
+	if returnsMediaIDs(original.Server) {
+		if preview.Reader != nil {
+			preview.Reader.Close()
 		}
-
-		if err = validation.Validate(previewURL, validation.Required, is.URL); err != nil {
+		id, err := original.PutFile(ctx)
+		if err != nil {
 			return nil, err
 		}
+		return om.ImageID(id, "")
 	}
-
-	msg := map[string]string{
-		"type":        "image",
-		"originalUrl": originalURL,
-		"previewUrl":  previewURL,
+	if err := preview.Validate(MediaImage); err != nil {
+		return nil, err
 	}
-	txt, _ := json.Marshal(msg)
-	values.Add("message", string(txt))
-	return values, nil
-}
-
-func (om *OutboundMessage) ImageMS(original MediaServerMedia, preview MediaServerMedia) (url.Values, error) {
-	originalURL, err := original.PutFile()
+	originalURL, err := original.PutFile(ctx)
 	if err != nil {
 		return nil, err
 	}
-	previewURL, err := preview.PutFile()
+	previewURL, err := preview.PutFile(ctx)
 	if err != nil {
 		return nil, err
 	}
 	return om.Image(originalURL, previewURL)
 }
 
-//Audio creates an audio message
-func (om *OutboundMessage) Audio(url string) (url.Values, error) {
-	values, err := om.defaultValues()
+//ImageMSAutoPreview creates an image message generating the preview from the original.
+//The preview is a JPEG of at most PreviewMaxDimension pixels and PreviewMaxBytes,
+//both files are uploaded to the original's media server. With a MediaIDServer
+//no preview is generated and only the original is uploaded
+func (om *OutboundMessage) ImageMSAutoPreview(ctx context.Context, original MediaServerMedia) (url.Values, error) {
+	if returnsMediaIDs(original.Server) {
+		return om.ImageMS(ctx, original, MediaServerMedia{})
+	}
+	original, preview, err := withPreview(original)
 	if err != nil {
 		return nil, err
 	}
-	msg := map[string]string{
-		"type": "audio",
-		"url":  url,
-	}
-	txt, _ := json.Marshal(msg)
-	values.Add("message", string(txt))
-	return values, nil
+	return om.ImageMS(ctx, original, preview)
+}
+
+//Audio creates an audio message
+func (om *OutboundMessage) Audio(url string) (url.Values, error) {
+	return om.Build(AudioMessage{URL: url})
 }
 
-func (om *OutboundMessage) AudioMS(media MediaServerMedia) (url.Values, error) {
-	url, err := media.PutFile()
+//AudioID creates an audio message with a media uploaded with Client.UploadMedia
+func (om *OutboundMessage) AudioID(id string) (url.Values, error) {
+	return om.Build(AudioMessage{ID: id})
+}
+
+func (om *OutboundMessage) AudioMS(ctx context.Context, media MediaServerMedia) (url.Values, error) {
+	if err := media.Validate(MediaAudio); err != nil {
+		return nil, err
+	}
+	ref, err := media.PutFile(ctx)
 	if err != nil {
 		return nil, err
 	}
-	return om.Audio(url)
+	u, id := mediaRef(media.Server, ref)
+	return om.Build(AudioMessage{URL: u, ID: id})
 }
 
 //Video creates a video message
 func (om *OutboundMessage) Video(url string, caption string) (url.Values, error) {
-	values, err := om.defaultValues()
-	if err != nil {
+	return om.Build(VideoMessage{URL: url, Caption: caption})
+}
+
+//VideoID creates a video message with a media uploaded with Client.UploadMedia
+func (om *OutboundMessage) VideoID(id string, caption string) (url.Values, error) {
+	return om.Build(VideoMessage{ID: id, Caption: caption})
+}
+
+func (om *OutboundMessage) VideoMS(ctx context.Context, media MediaServerMedia, caption string) (url.Values, error) {
+	if err := media.Validate(MediaVideo); err != nil {
 		return nil, err
 	}
-	msg := map[string]string{
-		"type":    "video",
-		"url":     url,
-		"caption": caption,
+	ref, err := media.PutFile(ctx)
+	if err != nil {
+		return nil, err
 	}
-	txt, _ := json.Marshal(msg)
-	values.Add("message", string(txt))
-	return values, nil
+	u, id := mediaRef(media.Server, ref)
+	return om.Build(VideoMessage{URL: u, ID: id, Caption: caption})
+}
+
+//Document creates a document message, filename is the name shown to the user
+func (om *OutboundMessage) Document(url string, filename string) (url.Values, error) {
+	return om.Build(DocumentMessage{URL: url, Filename: filename})
+}
+
+//DocumentID creates a document message with a media uploaded with Client.UploadMedia
+func (om *OutboundMessage) DocumentID(id string, filename string) (url.Values, error) {
+	return om.Build(DocumentMessage{ID: id, Filename: filename})
 }
 
-func (om *OutboundMessage) VideoMS(media MediaServerMedia, caption string) (url.Values, error) {
-	url, err := media.PutFile()
+func (om *OutboundMessage) DocumentMS(ctx context.Context, media MediaServerMedia, filename string) (url.Values, error) {
+	if err := media.Validate(MediaDocument); err != nil {
+		return nil, err
+	}
+	ref, err := media.PutFile(ctx)
 	if err != nil {
 		return nil, err
 	}
-	return om.Video(url, caption)
+	u, id := mediaRef(media.Server, ref)
+	return om.Build(DocumentMessage{URL: u, ID: id, Filename: filename})
 }
 
 //Creates an interactive list message
 func (om *OutboundMessage) ListMessage(lm ListMessage) (url.Values, error) {
-	values, err := om.defaultValues()
-	if err != nil {
-		return nil, err
-	}
-
-	if !om.DoNotValidate {
-		if err = lm.Validate(); err != nil {
-			return nil, err
-		}
-	}
-
-	txt, _ := json.Marshal(lm)
-	values.Add("message", string(txt))
-	return values, nil
-
+	return om.Build(lm)
 }
 
 type ListMessage struct {
@@ -181,8 +202,13 @@ type ListMessage struct {
 	Items        []ListItem `json:"items"`
 }
 
-func (lm *ListMessage) Validate() error {
-	return validation.ValidateStruct(lm,
+func (lm ListMessage) MessageType() string { return "list" }
+func (lm ListMessage) Encode() url.Values  { return encodeMessage(lm) }
+
+//Validate has a value receiver so ListMessage values passed to Build are validated,
+//*ListMessage still has it
+func (lm ListMessage) Validate() error {
+	return validation.ValidateStruct(&lm,
 		validation.Field(&lm.Title, validation.Required, validation.Length(1, 60)),
 		validation.Field(&lm.Body, validation.Required, validation.Length(1, 1024)),
 		validation.Field(&lm.Items, validation.Required, validation.Length(1, 10)),
@@ -214,6 +240,23 @@ func (lm ListMessage) MarshalJSON() ([]byte, error) {
 	return json.Marshal(tmp)
 }
 
+func (lm *ListMessage) UnmarshalJSON(data []byte) error {
+	type Alias ListMessage
+	var tmp struct {
+		Alias
+		Button []map[string]string `json:"globalButtons"`
+	}
+	if err := json.Unmarshal(data, &tmp); err != nil {
+		return err
+	}
+
+	*lm = ListMessage(tmp.Alias)
+	if len(tmp.Button) > 0 {
+		lm.GlobalButton = tmp.Button[0]["title"]
+	}
+	return nil
+}
+
 type ListItem struct {
 	Title   string           `json:"title"`
 	Options []ListItemOption `json:"options"`
@@ -255,51 +298,84 @@ func (li ListItemOption) MarshalJSON() ([]byte, error) {
 }
 
 func (om *OutboundMessage) QuickReplyText(text QuickReplyText) (url.Values, error) {
-	values, err := om.defaultValues()
-	if err != nil {
-		return nil, err
-	}
-	txt, _ := json.Marshal(text)
-	values.Add("message", string(txt))
-	return values, nil
+	return om.Build(text)
 }
 
 func (om *OutboundMessage) QuickReplyImage(qri QuickReplyImage) (url.Values, error) {
-	values, err := om.defaultValues()
-	if err != nil {
-		return nil, err
-	}
-	txt, _ := json.Marshal(qri)
-	values.Add("message", string(txt))
-	return values, nil
+	return om.Build(qri)
+}
+
+func (om *OutboundMessage) QuickReplyVideo(qrv QuickReplyVideo) (url.Values, error) {
+	return om.Build(qrv)
 }
 
 func (om *OutboundMessage) QuickReplyDocument(qrd QuickReplyDocument) (url.Values, error) {
-	values, err := om.defaultValues()
-	if err != nil {
-		return nil, err
-	}
-	txt, _ := json.Marshal(qrd)
-	values.Add("message", string(txt))
-	return values, nil
+	return om.Build(qrd)
 }
 
+//QuickReplyOption is the title of a quick reply button. Gupshup reads the
+//title of the options, sent as {"type":"text","title":...}
 type QuickReplyOption string
 
-func (qro *QuickReplyOption) MarshalJSON() ([]byte, error) {
+func (qro QuickReplyOption) MarshalJSON() ([]byte, error) {
 	type tmpli struct {
-		Opt  string `json:"text"`
-		Type string `json:"type"`
+		Type  string `json:"type"`
+		Title string `json:"title"`
 	}
 
 	tmp := tmpli{
-		Opt:  string(*qro),
-		Type: "text",
+		Type:  "text",
+		Title: string(qro),
 	}
 
 	return json.Marshal(tmp)
 }
 
+func (qro *QuickReplyOption) UnmarshalJSON(data []byte) error {
+	var title string
+	if json.Unmarshal(data, &title) == nil {
+		*qro = QuickReplyOption(title)
+		return nil
+	}
+
+	//options written with the text key by older versions are read too
+	var tmp struct {
+		Title string `json:"title"`
+		Text  string `json:"text"`
+	}
+	if err := json.Unmarshal(data, &tmp); err != nil {
+		return err
+	}
+	if tmp.Title == "" {
+		tmp.Title = tmp.Text
+	}
+	*qro = QuickReplyOption(tmp.Title)
+	return nil
+}
+
+// quickReply is the wire format shared by all quick reply messages
+type quickReply struct {
+	Type    string             `json:"type"`
+	MsgID   string             `json:"msgid"`
+	Content quickReplyContent  `json:"content"`
+	Options []QuickReplyOption `json:"options"`
+}
+
+type quickReplyContent struct {
+	Type     string `json:"type"`
+	Header   string `json:"header,omitempty"`
+	URL      string `json:"url,omitempty"`
+	Text     string `json:"text"`
+	Caption  string `json:"caption"`
+	Filename string `json:"filename,omitempty"`
+}
+
+func unmarshalQuickReply(data []byte) (quickReply, error) {
+	var qr quickReply
+	err := json.Unmarshal(data, &qr)
+	return qr, err
+}
+
 type QuickReplyImage struct {
 	MsgID   string
 	URL     string
@@ -308,129 +384,117 @@ type QuickReplyImage struct {
 	Options []QuickReplyOption
 }
 
-func (qri *QuickReplyImage) MarshalJSON() ([]byte, error) {
+func (qri QuickReplyImage) MessageType() string { return "quick_reply" }
+func (qri QuickReplyImage) Encode() url.Values  { return encodeMessage(qri) }
 
-	type TContent struct {
-		Type    string `json:"type"`
-		URL     string `json:"url"`
-		Text    string `json:"text"`
-		Caption string `json:"caption"`
-	}
-
-	tmpli := struct {
-		MsgID   string             `json:"msgid"`
-		Content TContent           `json:"content"`
-		Options []QuickReplyOption `json:"options"`
-	}{
+func (qri QuickReplyImage) MarshalJSON() ([]byte, error) {
+	return json.Marshal(quickReply{
+		Type:    qri.MessageType(),
 		MsgID:   qri.MsgID,
 		Options: qri.Options,
-		Content: TContent{
+		Content: quickReplyContent{
 			Type:    "image",
 			URL:     qri.URL,
 			Text:    qri.Text,
 			Caption: qri.Caption,
 		},
-	}
+	})
+}
 
-	return json.Marshal(tmpli)
+func (qri *QuickReplyImage) UnmarshalJSON(data []byte) error {
+	qr, err := unmarshalQuickReply(data)
+	if err != nil {
+		return err
+	}
+	*qri = QuickReplyImage{MsgID: qr.MsgID, URL: qr.Content.URL, Text: qr.Content.Text, Caption: qr.Content.Caption, Options: qr.Options}
+	return nil
 }
 
 type QuickReplyText struct {
-	MsgID   string `json:"msgid"`
-	Header  string `json:"header"`
-	Text    string `json:"text"`
-	Caption string `json:"caption"`
+	MsgID   string
+	Header  string
+	Text    string
+	Caption string
 	Options []QuickReplyOption
 }
 
-func (qrt *QuickReplyText) MarshalJSON() ([]byte, error) {
+func (qrt QuickReplyText) MessageType() string { return "quick_reply" }
+func (qrt QuickReplyText) Encode() url.Values  { return encodeMessage(qrt) }
 
-	type TContent struct {
-		Type    string `json:"type"`
-		Header  string `json:"header"`
-		Text    string `json:"text"`
-		Caption string `json:"caption"`
-	}
-
-	tmpli := struct {
-		MsgID   string `json:"msgid"`
-		Content TContent
-		Options []QuickReplyOption `json:"options"`
-	}{
+func (qrt QuickReplyText) MarshalJSON() ([]byte, error) {
+	return json.Marshal(quickReply{
+		Type:  qrt.MessageType(),
 		MsgID: qrt.MsgID,
-		Content: TContent{
+		Content: quickReplyContent{
 			Type:    "text",
 			Header:  qrt.Header,
 			Text:    qrt.Text,
 			Caption: qrt.Caption,
 		},
 		Options: qrt.Options,
-	}
+	})
+}
 
-	return json.Marshal(tmpli)
+func (qrt *QuickReplyText) UnmarshalJSON(data []byte) error {
+	qr, err := unmarshalQuickReply(data)
+	if err != nil {
+		return err
+	}
+	*qrt = QuickReplyText{MsgID: qr.MsgID, Header: qr.Content.Header, Text: qr.Content.Text, Caption: qr.Content.Caption, Options: qr.Options}
+	return nil
 }
 
 type QuickReplyVideo struct {
-	MsgID   string `json:"msgid"`
-	URL     string `json:"url"`
-	Text    string `json:"text"`
-	Caption string `json:"caption"`
+	MsgID   string
+	URL     string
+	Text    string
+	Caption string
 	Options []QuickReplyOption
 }
 
-func (qrv *QuickReplyVideo) MarshalJSON() ([]byte, error) {
-
-	type TContent struct {
-		Type    string `json:"type"`
-		URL     string `json:"url"`
-		Text    string `json:"text"`
-		Caption string `json:"caption"`
-	}
+func (qrv QuickReplyVideo) MessageType() string { return "quick_reply" }
+func (qrv QuickReplyVideo) Encode() url.Values  { return encodeMessage(qrv) }
 
-	tmpli := struct {
-		MsgID   string `json:"msgid"`
-		Content TContent
-		Options []QuickReplyOption `json:"options"`
-	}{
+func (qrv QuickReplyVideo) MarshalJSON() ([]byte, error) {
+	return json.Marshal(quickReply{
+		Type:  qrv.MessageType(),
 		MsgID: qrv.MsgID,
-		Content: TContent{
+		Content: quickReplyContent{
 			Type:    "video",
 			URL:     qrv.URL,
 			Text:    qrv.Text,
 			Caption: qrv.Caption,
 		},
 		Options: qrv.Options,
-	}
+	})
+}
 
-	return json.Marshal(tmpli)
+func (qrv *QuickReplyVideo) UnmarshalJSON(data []byte) error {
+	qr, err := unmarshalQuickReply(data)
+	if err != nil {
+		return err
+	}
+	*qrv = QuickReplyVideo{MsgID: qr.MsgID, URL: qr.Content.URL, Text: qr.Content.Text, Caption: qr.Content.Caption, Options: qr.Options}
+	return nil
 }
 
 type QuickReplyDocument struct {
-	MsgID    string `json:"msgid"`
-	URL      string `json:"url"`
-	Text     string `json:"text"`
-	Caption  string `json:"caption"`
-	Filename string `json:"filename"`
+	MsgID    string
+	URL      string
+	Text     string
+	Caption  string
+	Filename string
 	Options  []QuickReplyOption
 }
 
-func (qrd *QuickReplyDocument) MarshalJSON() ([]byte, error) {
-
-	type TContent struct {
-		Type     string `json:"type"`
-		URL      string `json:"url"`
-		Text     string `json:"text"`
-		Caption  string `json:"caption"`
-		Filename string `json:"filename"`
-	}
+func (qrd QuickReplyDocument) MessageType() string { return "quick_reply" }
+func (qrd QuickReplyDocument) Encode() url.Values  { return encodeMessage(qrd) }
 
-	tmpli := struct {
-		MsgID   string             `json:"msgid"`
-		Content TContent           `json:"content"`
-		Options []QuickReplyOption `json:"options"`
-	}{
+func (qrd QuickReplyDocument) MarshalJSON() ([]byte, error) {
+	return json.Marshal(quickReply{
+		Type:  qrd.MessageType(),
 		MsgID: qrd.MsgID,
-		Content: TContent{
+		Content: quickReplyContent{
 			Type:     "document",
 			URL:      qrd.URL,
 			Text:     qrd.Text,
@@ -438,7 +502,14 @@ func (qrd *QuickReplyDocument) MarshalJSON() ([]byte, error) {
 			Filename: qrd.Filename,
 		},
 		Options: qrd.Options,
-	}
+	})
+}
 
-	return json.Marshal(tmpli)
+func (qrd *QuickReplyDocument) UnmarshalJSON(data []byte) error {
+	qr, err := unmarshalQuickReply(data)
+	if err != nil {
+		return err
+	}
+	*qrd = QuickReplyDocument{MsgID: qr.MsgID, URL: qr.Content.URL, Text: qr.Content.Text, Caption: qr.Content.Caption, Filename: qr.Content.Filename, Options: qr.Options}
+	return nil
 }
@@ -11,7 +11,7 @@ import (
 func ExampleOutboundMessage() {
 	om := &OutboundMessage{
 		Channel:     "whatsapp",
-		Destination: "+1234567890",
+		Destination: "+34600000001",
 		Source:      "+15555555555",
 		SourceName:  "Our Company",
 	}
@@ -44,3 +44,20 @@ func TestListMessageMarshal(t *testing.T) {
 		{"title":"test1","options":[{"type":"text","title":"test1_1","description":"test1_1_desc","postbackText":"test1_1_postback"},{"type":"text","title":"test1_2","description":"test1_2_desc","postbackText":"test1_2_postback"}]}
 	] }`, string(val))
 }
+
+func TestOutboundMessageDestination(t *testing.T) {
+	om := &OutboundMessage{
+		Channel:       "whatsapp",
+		Destination:   "600 000 001",
+		DefaultRegion: "ES",
+		Source:        "15555555555",
+		SourceName:    "Our Company",
+	}
+	values, err := om.Text("hi")
+	assert.NoError(t, err)
+	assert.Equal(t, "34600000001", values.Get("destination"))
+
+	om.DefaultRegion = ""
+	_, err = om.Text("hi")
+	assert.Error(t, err)
+}
@@ -0,0 +1,25 @@
+package wabaapi
+
+import (
+	"net/url"
+)
+
+// Template creates a template message. The template must be approved
+// and params are substituted in order into its placeholders
+func (om *OutboundMessage) Template(id string, params []string) (url.Values, error) {
+	return om.Build(TemplateMessage{ID: id, Params: params})
+}
+
+// TemplateInfo is a template as listed by Gupshup
+type TemplateInfo struct {
+	ID           string `json:"id"`
+	ElementName  string `json:"elementName"`
+	LanguageCode string `json:"languageCode"`
+	Category     string `json:"category"`
+	Status       string `json:"status"`
+	TemplateType string `json:"templateType"`
+	Data         string `json:"data"`
+	Meta         string `json:"meta,omitempty"`
+	CreatedOn    int64  `json:"createdOn"`
+	ModifiedOn   int64  `json:"modifiedOn"`
+}
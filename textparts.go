@@ -0,0 +1,201 @@
+package wabaapi
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/ansel1/merry"
+)
+
+// MaxTextLength is the maximum number of characters WhatsApp accepts in a text message
+const MaxTextLength = 4096
+
+// markerReserve is the room first left in every part for closing and reopening
+// formatting markers, SplitText grows it when nested spans need more
+const markerReserve = 6
+
+// TextParts splits text in messages of at most MaxTextLength characters and
+// returns them in the order they must be sent. When numbered is true every
+// part ends with " (n/total)".
+func (om *OutboundMessage) TextParts(text string, numbered bool) ([]url.Values, error) {
+	parts := SplitText(text, MaxTextLength, numbered)
+	if len(parts) == 0 {
+		return nil, merry.New("text cannot be empty")
+	}
+
+	out := make([]url.Values, 0, len(parts))
+	for _, part := range parts {
+		values, err := om.Text(part)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, values)
+	}
+	return out, nil
+}
+
+// SplitText splits text in parts of at most max characters. Parts are cut on
+// paragraph, line, sentence or word boundaries, in that order of preference,
+// and never inside a multi-byte character. Formatting spans cut in two are
+// closed at the end of a part and opened again in the next one.
+func SplitText(text string, max int, numbered bool) []string {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return nil
+	}
+	if utf8.RuneCountInString(text) <= max {
+		return []string{text}
+	}
+
+	//nested spans need more room than markerReserve to be closed and reopened,
+	//split again with the overflow reserved until every part fits
+	reserve := markerReserve
+	for {
+		parts := splitBalanced(text, max, numbered, reserve)
+		over := 0
+		for _, part := range parts {
+			if n := utf8.RuneCountInString(part) - max; n > over {
+				over = n
+			}
+		}
+		if over == 0 || reserve >= max {
+			return parts
+		}
+		reserve += over
+	}
+}
+
+// splitBalanced splits text leaving reserve characters in every part for the
+// formatting markers, balances the markers and numbers the parts
+func splitBalanced(text string, max int, numbered bool, reserve int) []string {
+	//the suffix length depends on the number of parts, grow it until it fits
+	var parts []string
+	for digits := 1; ; digits++ {
+		suffix := 0
+		if numbered {
+			suffix = len(fmt.Sprintf(" (%s/%s)", strings.Repeat("9", digits), strings.Repeat("9", digits)))
+		}
+		parts = splitRunes([]rune(text), max-suffix-reserve)
+		if !numbered || len(fmt.Sprint(len(parts))) <= digits {
+			break
+		}
+	}
+
+	parts = balanceMarkers(parts)
+	if numbered {
+		for i := range parts {
+			parts[i] = fmt.Sprintf("%s (%d/%d)", parts[i], i+1, len(parts))
+		}
+	}
+	return parts
+}
+
+func splitRunes(runes []rune, max int) []string {
+	if max < 1 {
+		max = 1
+	}
+
+	var parts []string
+	for len(runes) > max {
+		cut, next := findCut(runes[:max+1])
+		parts = append(parts, strings.TrimRightFunc(string(runes[:cut]), unicode.IsSpace))
+		runes = []rune(strings.TrimLeftFunc(string(runes[next:]), unicode.IsSpace))
+	}
+	if len(runes) > 0 {
+		parts = append(parts, string(runes))
+	}
+	return parts
+}
+
+// findCut returns where the current part ends and where the next one starts.
+// Boundaries in the first third of the window are ignored so parts are not too short
+func findCut(window []rune) (int, int) {
+	s := string(window[:len(window)-1])
+	shortest := len(s) / 3
+
+	for _, sep := range []string{"\n\n", "\n"} {
+		if i := strings.LastIndex(s, sep); i > shortest {
+			n := utf8.RuneCountInString(s[:i])
+			return n, n + utf8.RuneCountInString(sep)
+		}
+	}
+
+	for i := len(window) - 2; i > len(window)/3; i-- {
+		if unicode.IsSpace(window[i+1]) && strings.ContainsRune(".!?", window[i]) {
+			return i + 1, i + 1
+		}
+	}
+
+	for i := len(window) - 1; i > len(window)/3; i-- {
+		if unicode.IsSpace(window[i]) {
+			return i, i
+		}
+	}
+
+	//a single word longer than the window
+	return len(window) - 1, len(window) - 1
+}
+
+// balanceMarkers closes the formatting spans left open at the end of a part
+// and reopens them at the start of the next
+func balanceMarkers(parts []string) []string {
+	var open []string
+	for i, part := range parts {
+		part = strings.Join(open, "") + part
+		open = openMarkers(part)
+		for j := len(open) - 1; j >= 0; j-- {
+			part += open[j]
+		}
+		parts[i] = part
+	}
+	return parts
+}
+
+// openMarkers returns the formatting markers that are still open at the end of s
+func openMarkers(s string) []string {
+	var open []string
+	isOpen := func(m string) int {
+		for i, o := range open {
+			if o == m {
+				return i
+			}
+		}
+		return -1
+	}
+
+	rs := []rune(s)
+	for i := 0; i < len(rs); i++ {
+		if strings.HasPrefix(string(rs[i:minInt(i+3, len(rs))]), "```") {
+			if j := isOpen("```"); j >= 0 {
+				open = open[:j]
+			} else {
+				open = append(open, "```")
+			}
+			i += 2
+			continue
+		}
+		if isOpen("```") >= 0 || !strings.ContainsRune("*_~", rs[i]) {
+			continue
+		}
+
+		m := string(rs[i])
+		prevSpace := i == 0 || unicode.IsSpace(rs[i-1]) || unicode.IsPunct(rs[i-1])
+		nextSpace := i == len(rs)-1 || unicode.IsSpace(rs[i+1])
+		if j := isOpen(m); j >= 0 && !unicode.IsSpace(rs[i-1]) {
+			open = append(open[:j], open[j+1:]...)
+		} else if prevSpace && !nextSpace {
+			open = append(open, m)
+		}
+	}
+	return open
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
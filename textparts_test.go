@@ -0,0 +1,62 @@
+package wabaapi
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitText(t *testing.T) {
+	text := "First paragraph is short.\n\nSecond one has *bold words that keep going* and ñandú emojis 😀😀 until the end."
+
+	parts := SplitText(text, 40, true)
+	require.True(t, len(parts) > 1)
+	for i, part := range parts {
+		assert.LessOrEqual(t, utf8.RuneCountInString(part), 40, part)
+		assert.True(t, utf8.ValidString(part))
+		assert.Equal(t, strings.Count(part, "*")%2, 0, part)
+		assert.True(t, strings.HasSuffix(part, ")"), part)
+		assert.Contains(t, part, "/"+string(rune('0'+len(parts)))+")", i)
+	}
+	assert.Equal(t, "First paragraph is short. (1/4)", parts[0])
+}
+
+func TestTextParts(t *testing.T) {
+	om := &OutboundMessage{
+		Channel:     "whatsapp",
+		Destination: "+34600000001",
+		Source:      "15555555555",
+		SourceName:  "Our Company",
+	}
+
+	long := strings.Repeat("word ", MaxTextLength/5+10)
+	_, err := om.Text(long)
+	assert.Error(t, err)
+
+	values, err := om.TextParts(long, false)
+	require.NoError(t, err)
+	assert.Len(t, values, 2)
+}
+
+func TestSplitTextNestedMarkers(t *testing.T) {
+	om := &OutboundMessage{Channel: "whatsapp", Destination: "+34600000001", Source: "15555555555", SourceName: "Our Company"}
+
+	//bold, italic and strike spans plus a code block open across every cut
+	text := "*_~```" + strings.Repeat("word ", MaxTextLength/2)
+	for _, numbered := range []bool{false, true} {
+		parts := SplitText(text, MaxTextLength, numbered)
+		require.True(t, len(parts) > 1)
+		for _, part := range parts {
+			assert.LessOrEqual(t, utf8.RuneCountInString(part), MaxTextLength)
+			assert.Equal(t, 0, strings.Count(part, "```")%2, part[:10])
+		}
+		assert.True(t, strings.HasPrefix(parts[1], "*_~```"), parts[1][:10])
+
+		values, err := om.TextParts(text, numbered)
+		require.NoError(t, err)
+		assert.Len(t, values, len(parts))
+	}
+}
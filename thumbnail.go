@@ -0,0 +1,167 @@
+package wabaapi
+
+import (
+	"bytes"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"io/ioutil"
+
+	// GIF decoding for image.Decode
+	_ "image/gif"
+
+	"github.com/ansel1/merry"
+)
+
+var (
+	// PreviewMaxDimension is the maximum width and height of generated previews
+	PreviewMaxDimension = 320
+	// PreviewMaxBytes is the maximum size of generated previews
+	PreviewMaxBytes = 64 << 10
+	// ImageMaxPixels is the maximum width x height of the images decoded to
+	// generate previews, so small files cannot expand to huge bitmaps
+	ImageMaxPixels = 25000000
+)
+
+// ErrTooManyPixels is returned for images larger than ImageMaxPixels
+var ErrTooManyPixels = merry.New("image has too many pixels")
+
+// decodeImage decodes data after checking its dimensions against ImageMaxPixels
+func decodeImage(data []byte) (image.Image, error) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, merry.Errorf("failed to decode image: %s", err)
+	}
+	if cfg.Width*cfg.Height > ImageMaxPixels {
+		return nil, ErrTooManyPixels.Appendf("%dx%d exceeds %d pixels", cfg.Width, cfg.Height, ImageMaxPixels)
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, merry.Errorf("failed to decode image: %s", err)
+	}
+	return img, nil
+}
+
+// Thumbnail decodes a JPEG, PNG or GIF image and returns a JPEG that fits in
+// maxDimension x maxDimension and maxBytes, lowering the quality as needed
+func Thumbnail(r io.Reader, maxDimension int, maxBytes int) ([]byte, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, merry.Wrap(err)
+	}
+	img, err := decodeImage(data)
+	if err != nil {
+		return nil, err
+	}
+
+	thumb := resize(img, maxDimension)
+	var buf bytes.Buffer
+	for quality := 85; quality > 0; quality -= 15 {
+		buf.Reset()
+		if err := jpeg.Encode(&buf, thumb, &jpeg.Options{Quality: quality}); err != nil {
+			return nil, merry.Wrap(err)
+		}
+		if buf.Len() <= maxBytes {
+			return buf.Bytes(), nil
+		}
+	}
+	return nil, merry.Errorf("preview is larger than %d bytes", maxBytes)
+}
+
+// resize scales img down to fit in max x max averaging the source pixels
+// covered by every destination pixel. Smaller images are not scaled up
+func resize(img image.Image, max int) *image.RGBA {
+	b := img.Bounds()
+	src := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(src, src.Bounds(), img, b.Min, draw.Src)
+
+	w, h := b.Dx(), b.Dy()
+	if w <= max && h <= max {
+		return src
+	}
+	if w >= h {
+		w, h = max, h*max/w
+	} else {
+		w, h = w*max/h, max
+	}
+	if w < 1 {
+		w = 1
+	}
+	if h < 1 {
+		h = 1
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	sw, sh := src.Bounds().Dx(), src.Bounds().Dy()
+	for y := 0; y < h; y++ {
+		y0, y1 := y*sh/h, (y+1)*sh/h
+		for x := 0; x < w; x++ {
+			x0, x1 := x*sw/w, (x+1)*sw/w
+			var sum [4]int
+			n := 0
+			for sy := y0; sy < y1; sy++ {
+				off := src.PixOffset(x0, sy)
+				for sx := x0; sx < x1; sx++ {
+					for c := 0; c < 4; c++ {
+						sum[c] += int(src.Pix[off+c])
+					}
+					off += 4
+					n++
+				}
+			}
+			doff := dst.PixOffset(x, y)
+			for c := 0; c < 4; c++ {
+				dst.Pix[doff+c] = uint8(sum[c] / n)
+			}
+		}
+	}
+	return dst
+}
+
+// withPreview reads the original image and returns it together with a
+// generated preview. GIF originals are converted to PNG as WhatsApp does not
+// accept them
+func withPreview(original MediaServerMedia) (MediaServerMedia, MediaServerMedia, error) {
+	if original.Reader == nil {
+		return original, MediaServerMedia{}, merry.New("media reader not specified")
+	}
+	maxSize := MediaRules[MediaImage].MaxSize
+	data, err := ioutil.ReadAll(io.LimitReader(original.Reader, maxSize+1))
+	original.Reader.Close()
+	if err != nil {
+		return original, MediaServerMedia{}, merry.Wrap(err)
+	}
+	ctype := SniffContentType(data)
+	if int64(len(data)) > maxSize {
+		return original, MediaServerMedia{}, &MediaError{Kind: MediaImage, ContentType: ctype, Size: int64(len(data)), MaxSize: maxSize, Err: ErrMediaTooLarge}
+	}
+
+	if ctype == "image/gif" {
+		img, err := decodeImage(data)
+		if err != nil {
+			return original, MediaServerMedia{}, err
+		}
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, img); err != nil {
+			return original, MediaServerMedia{}, merry.Wrap(err)
+		}
+		data = buf.Bytes()
+		original.ContentType = "image/png"
+	}
+
+	thumb, err := Thumbnail(bytes.NewReader(data), PreviewMaxDimension, PreviewMaxBytes)
+	if err != nil {
+		return original, MediaServerMedia{}, err
+	}
+
+	original.Reader = ioutil.NopCloser(bytes.NewReader(data))
+	preview := MediaServerMedia{
+		Server:      original.Server,
+		Reader:      ioutil.NopCloser(bytes.NewReader(thumb)),
+		ContentType: "image/jpeg",
+	}
+	return original, preview, nil
+}
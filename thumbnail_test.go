@@ -0,0 +1,76 @@
+package wabaapi
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io/ioutil"
+	"testing"
+
+	"github.com/ansel1/merry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestThumbnail(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 1000, 500))
+	for x := 0; x < 1000; x++ {
+		for y := 0; y < 500; y++ {
+			img.Set(x, y, color.RGBA{uint8(x), uint8(y), 0, 255})
+		}
+	}
+	var buf bytes.Buffer
+	require.NoError(t, jpeg.Encode(&buf, img, nil))
+
+	thumb, err := Thumbnail(&buf, 320, PreviewMaxBytes)
+	require.NoError(t, err)
+	assert.LessOrEqual(t, len(thumb), PreviewMaxBytes)
+
+	cfg, err := jpeg.DecodeConfig(bytes.NewReader(thumb))
+	require.NoError(t, err)
+	assert.Equal(t, 320, cfg.Width)
+	assert.Equal(t, 160, cfg.Height)
+}
+
+func TestImageMSAutoPreview(t *testing.T) {
+	img := image.NewPaletted(image.Rect(0, 0, 600, 400), color.Palette{color.Black, color.White})
+	var buf bytes.Buffer
+	require.NoError(t, gif.Encode(&buf, img, nil))
+
+	om := &OutboundMessage{
+		Channel:     "whatsapp",
+		Destination: "+34600000001",
+		Source:      "15555555555",
+		SourceName:  "Our Company",
+	}
+	ms := &memMediaServer{}
+	values, err := om.ImageMSAutoPreview(context.Background(), MediaServerMedia{Server: ms, Reader: ioutil.NopCloser(&buf), ContentType: "image/gif"})
+	require.NoError(t, err)
+	assert.Contains(t, values.Get("message"), `"previewUrl"`)
+	//the preview is uploaded last
+	assert.Equal(t, "image/jpeg", ms.contentType)
+}
+
+func TestPreviewLimits(t *testing.T) {
+	img := image.NewPaletted(image.Rect(0, 0, 600, 400), color.Palette{color.Black, color.White})
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, img))
+
+	defer func(max int) { ImageMaxPixels = max }(ImageMaxPixels)
+	ImageMaxPixels = 600*400 - 1
+	_, _, err := withPreview(MediaServerMedia{Reader: ioutil.NopCloser(bytes.NewReader(buf.Bytes()))})
+	assert.True(t, merry.Is(err, ErrTooManyPixels), "%v", err)
+	_, err = Thumbnail(bytes.NewReader(buf.Bytes()), 320, PreviewMaxBytes)
+	assert.True(t, merry.Is(err, ErrTooManyPixels), "%v", err)
+	ImageMaxPixels = 600 * 400
+	_, err = Thumbnail(bytes.NewReader(buf.Bytes()), 320, PreviewMaxBytes)
+	assert.NoError(t, err)
+
+	large := append(buf.Bytes(), make([]byte, MediaRules[MediaImage].MaxSize)...)
+	_, _, err = withPreview(MediaServerMedia{Reader: ioutil.NopCloser(bytes.NewReader(large))})
+	assert.True(t, merry.Is(err, ErrMediaTooLarge), "%v", err)
+}
@@ -0,0 +1,256 @@
+package wabaapi
+
+// InboundEvent is the payload of an InboundMessage. It is implemented only by
+// the payload types of this package: UserEventPayload, SystemEventPayload,
+// AccountEventPayload, MessageEventPayload, InboundMessagePayload and
+// UnknownPayload, and by types embedding InboundExtension
+type InboundEvent interface {
+	inboundEvent()
+}
+
+// InboundContent is the payload of an InboundMessagePayload. It is implemented
+// only by the content types of this package: InboundText, InboundButtonText,
+// InboundMedia, InboundLocation, InboundContacts, InboundListReply,
+// InboundButtonReply, InboundReaction, InboundOrder, InboundProductInquiry,
+// InboundFlowReply and UnknownPayload, and by types embedding InboundExtension
+type InboundContent interface {
+	inboundContent()
+}
+
+func (UserEventPayload) inboundEvent()      {}
+func (SystemEventPayload) inboundEvent()    {}
+func (AccountEventPayload) inboundEvent()   {}
+func (MessageEventPayload) inboundEvent()   {}
+func (InboundMessagePayload) inboundEvent() {}
+
+func (InboundText) inboundContent()           {}
+func (InboundButtonText) inboundContent()     {}
+func (InboundMedia) inboundContent()          {}
+func (InboundLocation) inboundContent()       {}
+func (InboundContacts) inboundContent()       {}
+func (InboundListReply) inboundContent()      {}
+func (InboundButtonReply) inboundContent()    {}
+func (InboundReaction) inboundContent()       {}
+func (InboundOrder) inboundContent()          {}
+func (InboundProductInquiry) inboundContent() {}
+func (InboundFlowReply) inboundContent()      {}
+
+// AsMessage returns the payload of a message callback
+func (m InboundMessage) AsMessage() (InboundMessagePayload, bool) {
+	p, ok := m.Payload.(InboundMessagePayload)
+	return p, ok
+}
+
+// AsMessageEvent returns the payload of a message-event callback
+func (m InboundMessage) AsMessageEvent() (MessageEventPayload, bool) {
+	p, ok := m.Payload.(MessageEventPayload)
+	return p, ok
+}
+
+// AsUserEvent returns the payload of a user-event callback
+func (m InboundMessage) AsUserEvent() (UserEventPayload, bool) {
+	p, ok := m.Payload.(UserEventPayload)
+	return p, ok
+}
+
+// AsSystemEvent returns the payload of a system-event callback
+func (m InboundMessage) AsSystemEvent() (SystemEventPayload, bool) {
+	p, ok := m.Payload.(SystemEventPayload)
+	return p, ok
+}
+
+// AsAccountEvent returns the payload of an account-event callback
+func (m InboundMessage) AsAccountEvent() (AccountEventPayload, bool) {
+	p, ok := m.Payload.(AccountEventPayload)
+	return p, ok
+}
+
+// AsText returns the text of a text message. Quick reply button texts are
+// InboundButtonText and are not returned
+func (msg InboundMessagePayload) AsText() (string, bool) {
+	t, ok := msg.Payload.(InboundText)
+	return string(t), ok
+}
+
+// AsButtonText returns the text of a quick reply button pressed by the user
+func (msg InboundMessagePayload) AsButtonText() (string, bool) {
+	t, ok := msg.Payload.(InboundButtonText)
+	return string(t), ok
+}
+
+// AsReaction returns the reaction of a reaction message
+func (msg InboundMessagePayload) AsReaction() (InboundReaction, bool) {
+	r, ok := msg.Payload.(InboundReaction)
+	return r, ok
+}
+
+// AsOrder returns the cart of an order message
+func (msg InboundMessagePayload) AsOrder() (InboundOrder, bool) {
+	o, ok := msg.Payload.(InboundOrder)
+	return o, ok
+}
+
+// AsProductInquiry returns the text and product of a message about a catalog product
+func (msg InboundMessagePayload) AsProductInquiry() (InboundProductInquiry, bool) {
+	p, ok := msg.Payload.(InboundProductInquiry)
+	return p, ok
+}
+
+// AsFlowReply returns the response of a completed flow
+func (msg InboundMessagePayload) AsFlowReply() (InboundFlowReply, bool) {
+	r, ok := msg.Payload.(InboundFlowReply)
+	return r, ok
+}
+
+// AsMedia returns the media of an image, audio, video, sticker or file message
+func (msg InboundMessagePayload) AsMedia() (InboundMedia, bool) {
+	m, ok := msg.Payload.(InboundMedia)
+	return m, ok
+}
+
+// AsLocation returns the location of a location message
+func (msg InboundMessagePayload) AsLocation() (InboundLocation, bool) {
+	l, ok := msg.Payload.(InboundLocation)
+	return l, ok
+}
+
+// AsContacts returns the contacts of a contact message
+func (msg InboundMessagePayload) AsContacts() (InboundContacts, bool) {
+	c, ok := msg.Payload.(InboundContacts)
+	return c, ok
+}
+
+// AsListReply returns the option selected in a list message
+func (msg InboundMessagePayload) AsListReply() (InboundListReply, bool) {
+	r, ok := msg.Payload.(InboundListReply)
+	return r, ok
+}
+
+// AsButtonReply returns the button pressed in an interactive message
+func (msg InboundMessagePayload) AsButtonReply() (InboundButtonReply, bool) {
+	r, ok := msg.Payload.(InboundButtonReply)
+	return r, ok
+}
+
+// InboundVisitor has a method for every payload type. Handlers that must deal
+// with every type implement it directly so a new type breaks their build,
+// handlers interested in a few types embed NopInboundVisitor.
+// The InboundMessagePayload is passed along the content of messages for the
+// sender and context
+type InboundVisitor interface {
+	VisitUserEvent(m InboundMessage, p UserEventPayload) error
+	VisitSystemEvent(m InboundMessage, p SystemEventPayload) error
+	VisitAccountEvent(m InboundMessage, p AccountEventPayload) error
+	VisitMessageEvent(m InboundMessage, p MessageEventPayload) error
+	VisitUnknownEvent(m InboundMessage, p UnknownPayload) error
+	// VisitExtensionEvent is called for payloads of registered decoders
+	VisitExtensionEvent(m InboundMessage, p InboundEvent) error
+
+	VisitText(msg InboundMessagePayload, t InboundText) error
+	VisitButtonText(msg InboundMessagePayload, t InboundButtonText) error
+	VisitMedia(msg InboundMessagePayload, media InboundMedia) error
+	VisitLocation(msg InboundMessagePayload, loc InboundLocation) error
+	VisitContacts(msg InboundMessagePayload, contacts InboundContacts) error
+	VisitListReply(msg InboundMessagePayload, reply InboundListReply) error
+	VisitButtonReply(msg InboundMessagePayload, reply InboundButtonReply) error
+	VisitReaction(msg InboundMessagePayload, reaction InboundReaction) error
+	VisitOrder(msg InboundMessagePayload, order InboundOrder) error
+	VisitProductInquiry(msg InboundMessagePayload, inquiry InboundProductInquiry) error
+	VisitFlowReply(msg InboundMessagePayload, reply InboundFlowReply) error
+	VisitUnknownContent(msg InboundMessagePayload, p UnknownPayload) error
+	// VisitExtensionContent is called for contents of registered decoders
+	VisitExtensionContent(msg InboundMessagePayload, c InboundContent) error
+}
+
+// Accept calls the method of v for the payload of m
+func (m InboundMessage) Accept(v InboundVisitor) error {
+	switch p := m.Payload.(type) {
+	case nil:
+		return nil
+	case UserEventPayload:
+		return v.VisitUserEvent(m, p)
+	case SystemEventPayload:
+		return v.VisitSystemEvent(m, p)
+	case AccountEventPayload:
+		return v.VisitAccountEvent(m, p)
+	case MessageEventPayload:
+		return v.VisitMessageEvent(m, p)
+	case InboundMessagePayload:
+		return p.Accept(v)
+	case UnknownPayload:
+		return v.VisitUnknownEvent(m, p)
+	}
+	return v.VisitExtensionEvent(m, m.Payload)
+}
+
+// Accept calls the method of v for the content of msg
+func (msg InboundMessagePayload) Accept(v InboundVisitor) error {
+	switch c := msg.Payload.(type) {
+	case nil:
+		return nil
+	case InboundText:
+		return v.VisitText(msg, c)
+	case InboundButtonText:
+		return v.VisitButtonText(msg, c)
+	case InboundMedia:
+		return v.VisitMedia(msg, c)
+	case InboundLocation:
+		return v.VisitLocation(msg, c)
+	case InboundContacts:
+		return v.VisitContacts(msg, c)
+	case InboundListReply:
+		return v.VisitListReply(msg, c)
+	case InboundButtonReply:
+		return v.VisitButtonReply(msg, c)
+	case InboundReaction:
+		return v.VisitReaction(msg, c)
+	case InboundOrder:
+		return v.VisitOrder(msg, c)
+	case InboundProductInquiry:
+		return v.VisitProductInquiry(msg, c)
+	case InboundFlowReply:
+		return v.VisitFlowReply(msg, c)
+	case UnknownPayload:
+		return v.VisitUnknownContent(msg, c)
+	}
+	return v.VisitExtensionContent(msg, msg.Payload)
+}
+
+// NopInboundVisitor implements InboundVisitor ignoring every payload
+type NopInboundVisitor struct{}
+
+var _ InboundVisitor = NopInboundVisitor{}
+
+func (NopInboundVisitor) VisitUserEvent(InboundMessage, UserEventPayload) error       { return nil }
+func (NopInboundVisitor) VisitSystemEvent(InboundMessage, SystemEventPayload) error   { return nil }
+func (NopInboundVisitor) VisitAccountEvent(InboundMessage, AccountEventPayload) error { return nil }
+func (NopInboundVisitor) VisitMessageEvent(InboundMessage, MessageEventPayload) error { return nil }
+func (NopInboundVisitor) VisitText(InboundMessagePayload, InboundText) error          { return nil }
+func (NopInboundVisitor) VisitButtonText(InboundMessagePayload, InboundButtonText) error {
+	return nil
+}
+func (NopInboundVisitor) VisitMedia(InboundMessagePayload, InboundMedia) error       { return nil }
+func (NopInboundVisitor) VisitLocation(InboundMessagePayload, InboundLocation) error { return nil }
+func (NopInboundVisitor) VisitContacts(InboundMessagePayload, InboundContacts) error { return nil }
+func (NopInboundVisitor) VisitListReply(InboundMessagePayload, InboundListReply) error {
+	return nil
+}
+func (NopInboundVisitor) VisitButtonReply(InboundMessagePayload, InboundButtonReply) error {
+	return nil
+}
+func (NopInboundVisitor) VisitReaction(InboundMessagePayload, InboundReaction) error { return nil }
+func (NopInboundVisitor) VisitOrder(InboundMessagePayload, InboundOrder) error       { return nil }
+func (NopInboundVisitor) VisitProductInquiry(InboundMessagePayload, InboundProductInquiry) error {
+	return nil
+}
+func (NopInboundVisitor) VisitFlowReply(InboundMessagePayload, InboundFlowReply) error {
+	return nil
+}
+func (NopInboundVisitor) VisitUnknownEvent(InboundMessage, UnknownPayload) error { return nil }
+func (NopInboundVisitor) VisitExtensionEvent(InboundMessage, InboundEvent) error { return nil }
+func (NopInboundVisitor) VisitUnknownContent(InboundMessagePayload, UnknownPayload) error {
+	return nil
+}
+func (NopInboundVisitor) VisitExtensionContent(InboundMessagePayload, InboundContent) error {
+	return nil
+}
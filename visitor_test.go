@@ -0,0 +1,76 @@
+package wabaapi
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recordingVisitor struct {
+	NopInboundVisitor
+	texts   []string
+	buttons []string
+	media   []InboundMedia
+	events  []string
+}
+
+func (v *recordingVisitor) VisitText(msg InboundMessagePayload, t InboundText) error {
+	v.texts = append(v.texts, string(t))
+	return nil
+}
+
+func (v *recordingVisitor) VisitButtonText(msg InboundMessagePayload, t InboundButtonText) error {
+	v.buttons = append(v.buttons, string(t))
+	return nil
+}
+
+func (v *recordingVisitor) VisitMedia(msg InboundMessagePayload, media InboundMedia) error {
+	v.media = append(v.media, media)
+	return nil
+}
+
+func (v *recordingVisitor) VisitMessageEvent(m InboundMessage, p MessageEventPayload) error {
+	v.events = append(v.events, p.Type)
+	return nil
+}
+
+func TestInboundVisitor(t *testing.T) {
+	callbacks := []string{
+		`{"app":"app","timestamp":1580227766370,"type":"message","payload":{"id":"1","source":"34600000001","type":"text","payload":{"text":"hi"},"sender":{"phone":"34600000001"}}}`,
+		`{"app":"app","timestamp":1580227766370,"type":"message","payload":{"id":"2","source":"34600000001","type":"text","payload":{"text":"Yes","type":"button"}}}`,
+		`{"app":"app","timestamp":1580227766370,"type":"message","payload":{"id":"3","source":"34600000001","type":"image","payload":{"url":"https://example.com/a.jpg","contentType":"image/jpeg"}}}`,
+		`{"app":"app","timestamp":1580227766370,"type":"message-event","payload":{"id":"4","gsId":"g4","type":"delivered","destination":"34600000001","payload":{}}}`,
+		`{"app":"app","timestamp":1580227766370,"type":"user-event","payload":{"phone":"34600000001","type":"opted-in"}}`,
+		`{"app":"app","timestamp":1580227766370,"type":"unknown-event","payload":{}}`,
+	}
+
+	v := &recordingVisitor{}
+	for _, cb := range callbacks {
+		var m InboundMessage
+		require.NoError(t, json.Unmarshal([]byte(cb), &m))
+		require.NoError(t, m.Accept(v))
+	}
+
+	assert.Equal(t, []string{"hi"}, v.texts)
+	assert.Equal(t, []string{"Yes"}, v.buttons)
+	require.Len(t, v.media, 1)
+	assert.Equal(t, "image", v.media[0].Type)
+	assert.Equal(t, []string{"delivered"}, v.events)
+}
+
+func TestInboundAccessors(t *testing.T) {
+	var m InboundMessage
+	require.NoError(t, json.Unmarshal([]byte(`{"app":"app","timestamp":1580227766370,"type":"message","payload":{"id":"1","source":"34600000001","type":"text","payload":{"text":"hi"}}}`), &m))
+
+	msg, ok := m.AsMessage()
+	require.True(t, ok)
+	text, ok := msg.AsText()
+	assert.True(t, ok)
+	assert.Equal(t, "hi", text)
+	_, ok = msg.AsMedia()
+	assert.False(t, ok)
+	_, ok = m.AsMessageEvent()
+	assert.False(t, ok)
+}
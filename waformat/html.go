@@ -0,0 +1,153 @@
+package waformat
+
+import (
+	"bytes"
+	"io"
+	"strings"
+
+	"github.com/ansel1/merry"
+	"golang.org/x/net/html"
+)
+
+// FromHTML converts simple HTML to WhatsApp formatting. b/strong, i/em,
+// s/strike/del and code/pre are converted, p, br, div and li produce line
+// breaks and links are written as "text (href)". Text is escaped, except for
+// URLs like FromMarkdown, and any other tag is dropped keeping its content.
+func FromHTML(src string) (string, error) {
+	z := html.NewTokenizer(strings.NewReader(src))
+
+	var sb bytes.Buffer
+	var hrefs []string
+	pre := 0
+	for {
+		tt := z.Next()
+		switch tt {
+		case html.ErrorToken:
+			if err := z.Err(); err != io.EOF {
+				return "", merry.Errorf("failed to parse html: %s", err)
+			}
+			return strings.TrimSpace(collapseBlankLines(sb.String())), nil
+		case html.TextToken:
+			text := string(z.Text())
+			if pre > 0 {
+				sb.WriteString(text)
+				continue
+			}
+			sb.WriteString(escapeText(collapseSpaces(text, sb.String())))
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, hasAttr := z.TagName()
+			tag := string(name)
+			switch tag {
+			case "b", "strong":
+				sb.WriteString("*")
+			case "i", "em":
+				sb.WriteString("_")
+			case "s", "strike", "del":
+				sb.WriteString("~")
+			case "code", "pre", "tt":
+				if pre == 0 {
+					sb.WriteString("```")
+				}
+				pre++
+			case "br":
+				sb.WriteString("\n")
+			case "p", "div":
+				newParagraph(&sb)
+			case "li":
+				newLine(&sb)
+				sb.WriteString("- ")
+			case "blockquote":
+				newLine(&sb)
+				sb.WriteString("> ")
+			case "a":
+				href := ""
+				for hasAttr {
+					var key, val []byte
+					key, val, hasAttr = z.TagAttr()
+					if string(key) == "href" {
+						href = string(val)
+					}
+				}
+				hrefs = append(hrefs, href)
+			}
+		case html.EndTagToken:
+			name, _ := z.TagName()
+			switch string(name) {
+			case "b", "strong":
+				closeMarker(&sb, "*")
+			case "i", "em":
+				closeMarker(&sb, "_")
+			case "s", "strike", "del":
+				closeMarker(&sb, "~")
+			case "code", "pre", "tt":
+				pre--
+				if pre == 0 {
+					sb.WriteString("```")
+				}
+			case "p", "div", "ul", "ol", "blockquote":
+				newParagraph(&sb)
+			case "a":
+				if len(hrefs) == 0 {
+					continue
+				}
+				href := hrefs[len(hrefs)-1]
+				hrefs = hrefs[:len(hrefs)-1]
+				if href != "" && !strings.HasSuffix(sb.String(), href) {
+					sb.WriteString(" (" + href + ")")
+				}
+			}
+		}
+	}
+}
+
+// collapseSpaces collapses HTML whitespace like a browser would
+func collapseSpaces(text string, written string) string {
+	collapsed := strings.Join(strings.Fields(text), " ")
+	if collapsed == "" {
+		if strings.TrimSpace(text) == "" && text != "" && !endsWithSpace(written) {
+			return " "
+		}
+		return ""
+	}
+	if startsWithSpace(text) && !endsWithSpace(written) {
+		collapsed = " " + collapsed
+	}
+	if endsWithSpace(text) {
+		collapsed += " "
+	}
+	return collapsed
+}
+
+func startsWithSpace(s string) bool {
+	return s != "" && strings.TrimLeft(s[:1], " \t\r\n") == ""
+}
+
+func endsWithSpace(s string) bool {
+	return s == "" || strings.TrimRight(s[len(s)-1:], " \t\r\n") == ""
+}
+
+// closeMarker writes the closing marker before any trailing space, WhatsApp
+// ignores markers preceded by a space
+func closeMarker(sb *bytes.Buffer, marker string) {
+	trailing := 0
+	for b := sb.Bytes(); trailing < len(b) && b[len(b)-1-trailing] == ' '; trailing++ {
+	}
+	sb.Truncate(sb.Len() - trailing)
+	sb.WriteString(marker + strings.Repeat(" ", trailing))
+}
+
+func newLine(sb *bytes.Buffer) {
+	if sb.Len() > 0 && !strings.HasSuffix(sb.String(), "\n") {
+		sb.WriteString("\n")
+	}
+}
+
+func newParagraph(sb *bytes.Buffer) {
+	if sb.Len() == 0 {
+		return
+	}
+	newLine(sb)
+	if !strings.HasSuffix(sb.String(), "\n\n") {
+		sb.WriteString("\n")
+	}
+}
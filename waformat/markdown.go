@@ -0,0 +1,167 @@
+package waformat
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var (
+	mdHeading    = regexp.MustCompile(`^#{1,6}\s+(.*?)\s*#*$`)
+	mdBullet     = regexp.MustCompile(`^(\s*)[-*+]\s+(.*)$`)
+	mdQuote      = regexp.MustCompile(`^>\s?(.*)$`)
+	mdRule       = regexp.MustCompile(`^\s*([-*_])(\s*[-*_]){2,}\s*$`)
+	mdEscape     = regexp.MustCompile("\\\\([\\\\*_~`\\[\\]()#>+\\-.!])")
+	mdCode       = regexp.MustCompile("`([^`]+)`")
+	mdLink       = regexp.MustCompile(`\[([^\]]+)\]\(([^)\s]+)\)`)
+	mdURL        = regexp.MustCompile(`https?://\S+`)
+	mdBoldStar   = regexp.MustCompile(`\*\*(\S(?:.*?\S)?)\*\*`)
+	mdBoldUnder  = regexp.MustCompile(`__(\S(?:.*?\S)?)__`)
+	mdItalicStar = regexp.MustCompile(`\*([^*\s](?:[^*]*[^*\s])?)\*`)
+	mdItalicUnd  = regexp.MustCompile(`^_([^_\s](?:[^_]*[^_\s])?)_`)
+	mdStrike     = regexp.MustCompile(`~~(\S(?:.*?\S)?)~~`)
+	placeholder  = regexp.MustCompile("\x04([0-9]+)\x05")
+
+	// sentinels removes the bytes used as markers and placeholders from the input
+	sentinels = strings.NewReplacer("\x01", "", "\x02", "", "\x03", "", "\x04", "", "\x05", "")
+)
+
+// markers used while converting so bold output is not read again as italic
+const (
+	tmpBold   = "\x01"
+	tmpItalic = "\x02"
+	tmpStrike = "\x03"
+)
+
+// FromMarkdown converts Markdown to WhatsApp formatting. Emphasis, strong,
+// strikethrough, code spans and fenced code blocks are converted, headings
+// become bold lines, links are written as "text (url)" and list items use "- ".
+// Any other Markdown is kept as text, escaped like FromHTML escapes text.
+// Control bytes \x01 to \x05 are removed, they are used while converting.
+func FromMarkdown(md string) string {
+	lines := strings.Split(strings.ReplaceAll(sentinels.Replace(md), "\r\n", "\n"), "\n")
+	out := make([]string, 0, len(lines))
+
+	var fence []string
+	inFence := false
+	for _, line := range lines {
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			if inFence {
+				out = append(out, Mono(strings.Join(fence, "\n")))
+				fence = nil
+			}
+			inFence = !inFence
+			continue
+		}
+		if inFence {
+			fence = append(fence, line)
+			continue
+		}
+
+		switch {
+		case mdRule.MatchString(line):
+			out = append(out, "")
+		case mdHeading.MatchString(line):
+			out = append(out, wrap("*", inline(mdHeading.FindStringSubmatch(line)[1])))
+		case mdBullet.MatchString(line):
+			m := mdBullet.FindStringSubmatch(line)
+			out = append(out, m[1]+"- "+inline(m[2]))
+		case mdQuote.MatchString(line):
+			out = append(out, "> "+inline(mdQuote.FindStringSubmatch(line)[1]))
+		default:
+			out = append(out, inline(line))
+		}
+	}
+	if inFence {
+		out = append(out, Mono(strings.Join(fence, "\n")))
+	}
+
+	return strings.TrimSpace(collapseBlankLines(strings.Join(out, "\n")))
+}
+
+// inline converts the inline Markdown of a single line
+func inline(s string) string {
+	var saved []string
+	save := func(v string) string {
+		saved = append(saved, v)
+		return "\x04" + strconv.Itoa(len(saved)-1) + "\x05"
+	}
+
+	s = mdEscape.ReplaceAllStringFunc(s, func(m string) string {
+		return save(Escape(m[1:]))
+	})
+	s = mdCode.ReplaceAllStringFunc(s, func(m string) string {
+		return save(Mono(m[1 : len(m)-1]))
+	})
+	s = mdLink.ReplaceAllStringFunc(s, func(m string) string {
+		sm := mdLink.FindStringSubmatch(m)
+		if sm[1] == sm[2] {
+			return save(sm[2])
+		}
+		return sm[1] + " (" + save(sm[2]) + ")"
+	})
+	s = mdURL.ReplaceAllStringFunc(s, save)
+
+	s = mdBoldStar.ReplaceAllString(s, tmpBold+"$1"+tmpBold)
+	s = mdBoldUnder.ReplaceAllString(s, tmpBold+"$1"+tmpBold)
+	s = mdStrike.ReplaceAllString(s, tmpStrike+"$1"+tmpStrike)
+	s = mdItalicStar.ReplaceAllString(s, tmpItalic+"$1"+tmpItalic)
+	s = italicUnderscores(s)
+
+	//markers left are plain text, escaped like FromHTML does
+	s = Escape(s)
+	s = strings.NewReplacer(tmpBold, "*", tmpItalic, "_", tmpStrike, "~").Replace(s)
+
+	return placeholder.ReplaceAllStringFunc(s, func(m string) string {
+		i, err := strconv.Atoi(m[1 : len(m)-1])
+		if err != nil || i >= len(saved) {
+			return ""
+		}
+		return saved[i]
+	})
+}
+
+// italicUnderscores converts _italic_ spans that are not inside a word, like
+// snake_case_name. The characters around a span are checked without being
+// consumed so adjacent spans like "_a_ _b_" are all converted
+func italicUnderscores(s string) string {
+	var sb strings.Builder
+	for i := 0; i < len(s); {
+		if s[i] == '_' && !isWordEnd(s[:i]) {
+			if m := mdItalicUnd.FindStringSubmatch(s[i:]); m != nil && !isWordStart(s[i+len(m[0]):]) {
+				sb.WriteString(tmpItalic + m[1] + tmpItalic)
+				i += len(m[0])
+				continue
+			}
+		}
+		sb.WriteByte(s[i])
+		i++
+	}
+	return sb.String()
+}
+
+func isWordRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// isWordEnd reports whether s ends with a word character
+func isWordEnd(s string) bool {
+	r, n := utf8.DecodeLastRuneInString(s)
+	return n > 0 && isWordRune(r)
+}
+
+// isWordStart reports whether s starts with a word character
+func isWordStart(s string) bool {
+	r, n := utf8.DecodeRuneInString(s)
+	return n > 0 && isWordRune(r)
+}
+
+// collapseBlankLines leaves at most one empty line between paragraphs
+func collapseBlankLines(s string) string {
+	for strings.Contains(s, "\n\n\n") {
+		s = strings.ReplaceAll(s, "\n\n\n", "\n\n")
+	}
+	return s
+}
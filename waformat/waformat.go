@@ -0,0 +1,133 @@
+// Package waformat writes WhatsApp rich text: *bold*, _italic_, ~strike~ and
+// ```mono```. It converts Markdown and simple HTML and has a Builder for
+// composing messages from user provided values. The resulting strings can be
+// used in OutboundMessage.Text, captions or ListMessage.Body.
+package waformat
+
+import (
+	"strings"
+)
+
+// zwsp is inserted after formatting markers in plain text so WhatsApp does not
+// read them as formatting. It is not visible in the message.
+const zwsp = "\u200b"
+
+var escaper = strings.NewReplacer(
+	"*", "*"+zwsp,
+	"_", "_"+zwsp,
+	"~", "~"+zwsp,
+	"`", "`"+zwsp,
+)
+
+// Escape neutralizes formatting markers in s, so a value like a customer
+// name containing "_" or "*" is shown as typed
+func Escape(s string) string {
+	return escaper.Replace(s)
+}
+
+// escapeText escapes s like Escape except for the URLs in it, so the links
+// WhatsApp shows keep working
+func escapeText(s string) string {
+	var sb strings.Builder
+	last := 0
+	for _, loc := range mdURL.FindAllStringIndex(s, -1) {
+		sb.WriteString(Escape(s[last:loc[0]]))
+		sb.WriteString(s[loc[0]:loc[1]])
+		last = loc[1]
+	}
+	sb.WriteString(Escape(s[last:]))
+	return sb.String()
+}
+
+// Bold returns s escaped and wrapped in bold markers
+func Bold(s string) string {
+	return wrap("*", Escape(s))
+}
+
+// Italic returns s escaped and wrapped in italic markers
+func Italic(s string) string {
+	return wrap("_", Escape(s))
+}
+
+// Strike returns s escaped and wrapped in strikethrough markers
+func Strike(s string) string {
+	return wrap("~", Escape(s))
+}
+
+// Mono returns s wrapped in monospace markers. Text inside is never formatted
+// so it is not escaped
+func Mono(s string) string {
+	if s == "" {
+		return ""
+	}
+	return "```" + s + "```"
+}
+
+// wrap adds the marker around s. WhatsApp ignores markers next to spaces so
+// surrounding whitespace is kept outside of them
+func wrap(marker string, s string) string {
+	trimmed := strings.TrimSpace(s)
+	if trimmed == "" {
+		return s
+	}
+	start := strings.Index(s, trimmed)
+	return s[:start] + marker + trimmed + marker + s[start+len(trimmed):]
+}
+
+// Builder composes a formatted message. Values are escaped, use Raw for
+// text that is already formatted.
+type Builder struct {
+	sb strings.Builder
+}
+
+// Text appends s escaped
+func (b *Builder) Text(s string) *Builder {
+	b.sb.WriteString(Escape(s))
+	return b
+}
+
+// Bold appends s in bold
+func (b *Builder) Bold(s string) *Builder {
+	b.sb.WriteString(Bold(s))
+	return b
+}
+
+// Italic appends s in italic
+func (b *Builder) Italic(s string) *Builder {
+	b.sb.WriteString(Italic(s))
+	return b
+}
+
+// Strike appends s with strikethrough
+func (b *Builder) Strike(s string) *Builder {
+	b.sb.WriteString(Strike(s))
+	return b
+}
+
+// Mono appends s in monospace
+func (b *Builder) Mono(s string) *Builder {
+	b.sb.WriteString(Mono(s))
+	return b
+}
+
+// Raw appends s as is
+func (b *Builder) Raw(s string) *Builder {
+	b.sb.WriteString(s)
+	return b
+}
+
+// Line appends a new line
+func (b *Builder) Line() *Builder {
+	b.sb.WriteString("\n")
+	return b
+}
+
+// Len returns the number of bytes written
+func (b *Builder) Len() int {
+	return b.sb.Len()
+}
+
+// String returns the formatted message
+func (b *Builder) String() string {
+	return b.sb.String()
+}
@@ -0,0 +1,56 @@
+package waformat
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFromMarkdown(t *testing.T) {
+	md := "# Order shipped\n\nHi **Ana**, your order is *on its way* and ~~late~~ on time.\n\n" +
+		"* Track it [here](https://example.com/t?id=a_b)\n* Code: `A_1*2`\n\n```\nline *1*\nline 2\n```\n\nsnake_case_name stays"
+
+	want := "*Order shipped*\n\nHi *Ana*, your order is _on its way_ and ~late~ on time.\n\n" +
+		"- Track it here (https://example.com/t?id=a_b)\n- Code: ```A_1*2```\n\n```line *1*\nline 2```\n\nsnake_\u200bcase_\u200bname stays"
+
+	assert.Equal(t, want, FromMarkdown(md))
+}
+
+func TestFromMarkdownAdjacentItalic(t *testing.T) {
+	assert.Equal(t, "_a_ _b_", FromMarkdown("_a_ _b_"))
+	assert.Equal(t, "(_a_)_b_", FromMarkdown("(_a_)_b_"))
+	assert.Equal(t, "_one_, _two_ and 2 *\u200b 3", FromMarkdown("_one_, _two_ and 2 * 3"))
+}
+
+func TestFromMarkdownControlBytes(t *testing.T) {
+	assert.NotPanics(t, func() { FromMarkdown("a \x047\x05 b") })
+	assert.Equal(t, "a 7 b", FromMarkdown("a \x047\x05 b"))
+	assert.Equal(t, "a b c", FromMarkdown("a \x01b\x02 c\x03"))
+	assert.Equal(t, "```x```", FromMarkdown("`x\x01`"))
+}
+
+func TestEscapeConsistent(t *testing.T) {
+	got, err := FromHTML("<p>snake_case_name</p>")
+	require.NoError(t, err)
+	assert.Equal(t, got, FromMarkdown("snake_case_name"))
+}
+
+func TestURLsNotEscaped(t *testing.T) {
+	got, err := FromHTML(`<p>See https://example.com/a_b*c and <a href="https://example.com/x_y">https://example.com/x_y</a> or a_b</p>`)
+	require.NoError(t, err)
+	assert.Equal(t, "See https://example.com/a_b*c and https://example.com/x_y or a_\u200bb", got)
+	assert.Equal(t, got, FromMarkdown("See https://example.com/a_b*c and [https://example.com/x_y](https://example.com/x_y) or a_b"))
+}
+
+func TestFromHTML(t *testing.T) {
+	got, err := FromHTML(`<p>Hi <b>Ana </b>&amp; <em>Luis</em>,</p><ul><li>Total: <code>10*2</code></li><li><a href="https://example.com">site</a></li></ul><p>user_name<br>bye</p>`)
+	require.NoError(t, err)
+	assert.Equal(t, "Hi *Ana* & _Luis_,\n\n- Total: ```10*2```\n- site (https://example.com)\n\nuser_\u200bname\nbye", got)
+}
+
+func TestBuilder(t *testing.T) {
+	var b Builder
+	b.Text("Hello ").Bold("*Ana*").Line().Italic(" see you ").Mono("x_y")
+	assert.Equal(t, "Hello *"+Escape("*Ana*")+"*\n _see you_ ```x_y```", b.String())
+}
@@ -0,0 +1,67 @@
+package wabaapi
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/ansel1/merry"
+)
+
+// WebhookHandler is an http.Handler for Gupshup callbacks. Every request body is
+// decoded into an InboundMessage and passed to HandleContext, when set, or Handle.
+// Decoder defaults to DefaultInboundDecoder
+type WebhookHandler struct {
+	Handle func(msg *InboundMessage) error
+	// HandleContext is Handle with the request context, which carries the
+	// Observer span of the callback
+	HandleContext func(ctx context.Context, msg *InboundMessage) error
+	Observer      Observer
+	Decoder       *InboundDecoder
+}
+
+func (wh *WebhookHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	ctx := r.Context()
+	done := func(string, string, error) {}
+	if wh.Observer != nil {
+		ctx, done = wh.Observer.WebhookStart(ctx)
+	}
+
+	dec := wh.Decoder
+	if dec == nil {
+		dec = DefaultInboundDecoder
+	}
+
+	var msg InboundMessage
+	body, err := ioutil.ReadAll(r.Body)
+	if err == nil {
+		msg, err = dec.Decode(body)
+	}
+	if err != nil {
+		done("", "", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	payloadType := fmt.Sprintf("%T", msg.Payload)
+	switch {
+	case wh.HandleContext != nil:
+		err = wh.HandleContext(ctx, &msg)
+	case wh.Handle != nil:
+		err = wh.Handle(&msg)
+	}
+	if err != nil {
+		done(msg.Type, payloadType, err)
+		http.Error(w, err.Error(), merry.HTTPCode(err))
+		return
+	}
+
+	done(msg.Type, payloadType, nil)
+	w.WriteHeader(http.StatusOK)
+}
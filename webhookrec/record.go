@@ -0,0 +1,186 @@
+// Package webhookrec records Gupshup callbacks to JSONL and replays the
+// recordings into any http.Handler, to reproduce production traffic in tests.
+package webhookrec
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ansel1/merry"
+)
+
+// Record is a callback as received, one per line in a recording
+type Record struct {
+	ReceivedAt time.Time   `json:"receivedAt"`
+	Method     string      `json:"method"`
+	Path       string      `json:"path"`
+	Header     http.Header `json:"header"`
+	// Body is the raw body when it is valid JSON, BodyText otherwise
+	Body     json.RawMessage `json:"body,omitempty"`
+	BodyText string          `json:"bodyText,omitempty"`
+}
+
+// Bytes returns the body as received
+func (rec *Record) Bytes() []byte {
+	if rec.Body != nil {
+		return rec.Body
+	}
+	return []byte(rec.BodyText)
+}
+
+// DefaultHeaders are the request headers recorded when Recorder.Headers is nil
+var DefaultHeaders = []string{"Content-Type", "User-Agent"}
+
+// Recorder writes every request that goes through Middleware to W
+type Recorder struct {
+	W io.Writer
+	// Headers are the request headers recorded, DefaultHeaders when nil. Any
+	// other header, like Authorization, Cookie or webhook secrets, is left out
+	// so recordings can be committed as fixtures
+	Headers []string
+	// Redact, when set, rewrites the body before it is written. See RedactPhones
+	Redact func(body []byte) []byte
+	// OnError is called when a record cannot be written, the request is served anyway
+	OnError func(err error)
+
+	mu sync.Mutex
+}
+
+// Middleware records the request and calls next with an untouched body
+func (rc *Recorder) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		r.Body.Close()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+		if err := rc.Write(Record{
+			ReceivedAt: time.Now(),
+			Method:     r.Method,
+			Path:       r.URL.Path,
+			Header:     rc.header(r.Header),
+		}, body); err != nil && rc.OnError != nil {
+			rc.OnError(err)
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// header returns the allowed headers of h
+func (rc *Recorder) header(h http.Header) http.Header {
+	names := rc.Headers
+	if names == nil {
+		names = DefaultHeaders
+	}
+	out := http.Header{}
+	for _, name := range names {
+		if v := h.Values(name); len(v) > 0 {
+			out[http.CanonicalHeaderKey(name)] = append([]string(nil), v...)
+		}
+	}
+	return out
+}
+
+// Write redacts body, sets it in rec and appends rec to the recording
+func (rc *Recorder) Write(rec Record, body []byte) error {
+	if rc.Redact != nil {
+		body = rc.Redact(body)
+	}
+	if json.Valid(body) {
+		var buf bytes.Buffer
+		if err := json.Compact(&buf, body); err != nil {
+			return merry.Wrap(err)
+		}
+		rec.Body = buf.Bytes()
+	} else {
+		rec.BodyText = string(body)
+	}
+
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return merry.Wrap(err)
+	}
+
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	_, err = rc.W.Write(append(line, '\n'))
+	return merry.Wrap(err)
+}
+
+// phoneKeys are the callback fields that hold phone numbers, dial_code is the
+// national number of the sender
+var phoneKeys = map[string]bool{
+	"phone":       true,
+	"source":      true,
+	"destination": true,
+	"dial_code":   true,
+}
+
+// RedactPhones replaces the phone numbers of a callback with pseudonyms. The
+// same number always gets the same pseudonym, with the same length and first
+// two digits, so conversations can still be followed in a recording.
+// Bodies that are not JSON are returned unchanged
+func RedactPhones(body []byte) []byte {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return body
+	}
+
+	out, err := json.Marshal(redact(v))
+	if err != nil {
+		return body
+	}
+	return out
+}
+
+func redact(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		phone, _ := t["phone"].(string)
+		dialCode, _ := t["dial_code"].(string)
+		for k, val := range t {
+			if s, ok := val.(string); ok && phoneKeys[k] {
+				t[k] = pseudonym(s)
+				continue
+			}
+			t[k] = redact(val)
+		}
+		// the national number keeps matching the redacted phone of the sender
+		if dialCode != "" && strings.HasSuffix(phone, dialCode) {
+			redacted := t["phone"].(string)
+			t["dial_code"] = redacted[len(redacted)-len(dialCode):]
+		}
+	case []interface{}:
+		for i := range t {
+			t[i] = redact(t[i])
+		}
+	}
+	return v
+}
+
+func pseudonym(phone string) string {
+	if len(phone) <= 4 {
+		return phone
+	}
+	sum := sha256.Sum256([]byte(phone))
+	out := []byte(phone)
+	for i := 2; i < len(out); i++ {
+		if out[i] >= '0' && out[i] <= '9' {
+			out[i] = '0' + sum[i%len(sum)]%10
+		}
+	}
+	return string(out)
+}
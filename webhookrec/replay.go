@@ -0,0 +1,95 @@
+package webhookrec
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/ansel1/merry"
+)
+
+// Result is the response of the handler to a replayed record
+type Result struct {
+	Record Record
+	Status int
+	Body   string
+}
+
+// Replayer feeds a recording into Handler. Use a wabaapi.WebhookHandler to
+// replay into a dispatcher func.
+type Replayer struct {
+	Handler http.Handler
+	// Speed scales the time between records: 1 keeps the original pacing, 10
+	// is ten times faster and 0 replays without waiting
+	Speed float64
+}
+
+// Replay reads records from r and serves them in order, returning the
+// handler's responses. It stops at the first malformed line or when ctx is done
+func (rp *Replayer) Replay(ctx context.Context, r io.Reader) ([]Result, error) {
+	if rp.Handler == nil {
+		return nil, merry.New("replayer handler not configured")
+	}
+
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64<<10), 16<<20)
+
+	var results []Result
+	var last time.Time
+	line := 0
+	for sc.Scan() {
+		line++
+		if len(bytes.TrimSpace(sc.Bytes())) == 0 {
+			continue
+		}
+
+		var rec Record
+		if err := json.Unmarshal(sc.Bytes(), &rec); err != nil {
+			return results, merry.Errorf("line %d: %s", line, err)
+		}
+
+		if rp.Speed > 0 && !last.IsZero() {
+			wait := time.Duration(float64(rec.ReceivedAt.Sub(last)) / rp.Speed)
+			if wait > 0 {
+				select {
+				case <-time.After(wait):
+				case <-ctx.Done():
+					return results, ctx.Err()
+				}
+			}
+		}
+		last = rec.ReceivedAt
+
+		if err := ctx.Err(); err != nil {
+			return results, err
+		}
+		results = append(results, rp.serve(ctx, rec))
+	}
+
+	return results, merry.Wrap(sc.Err())
+}
+
+func (rp *Replayer) serve(ctx context.Context, rec Record) Result {
+	method := rec.Method
+	if method == "" {
+		method = http.MethodPost
+	}
+	path := rec.Path
+	if path == "" {
+		path = "/"
+	}
+
+	req := httptest.NewRequest(method, path, bytes.NewReader(rec.Bytes())).WithContext(ctx)
+	for k, v := range rec.Header {
+		req.Header[k] = v
+	}
+
+	w := httptest.NewRecorder()
+	rp.Handler.ServeHTTP(w, req)
+	return Result{Record: rec, Status: w.Code, Body: w.Body.String()}
+}
@@ -0,0 +1,77 @@
+package webhookrec
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	wabaapi "github.com/panitaxx/gupshup-wabaapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const textCallback = `{"app":"app","timestamp":1639000000000,"version":2,"type":"message","payload":{"id":"m1","source":"34600000001","type":"text","payload":{"text":"hi"},"sender":{"phone":"34600000001","name":"Ana","country_code":"34","dial_code":"600000001"}}}`
+
+func TestRecordReplay(t *testing.T) {
+	var rec bytes.Buffer
+	recorder := &Recorder{W: &rec, Redact: RedactPhones}
+
+	var live []*wabaapi.InboundMessage
+	handler := recorder.Middleware(&wabaapi.WebhookHandler{Handle: func(msg *wabaapi.InboundMessage) error {
+		live = append(live, msg)
+		return nil
+	}})
+
+	for _, body := range []string{textCallback, "not json"} {
+		req := httptest.NewRequest(http.MethodPost, "/hook", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer secret-token")
+		req.Header.Set("Cookie", "session=secret-cookie")
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	require.Len(t, live, 1)
+	assert.Equal(t, "34600000001", live[0].Payload.(wabaapi.InboundMessagePayload).Source)
+	assert.Equal(t, 2, strings.Count(rec.String(), "\n"))
+	assert.NotContains(t, rec.String(), "34600000001")
+	assert.NotContains(t, rec.String(), "600000001")
+	assert.NotContains(t, rec.String(), "secret")
+	assert.Contains(t, rec.String(), "application/json")
+
+	var replayed []*wabaapi.InboundMessage
+	rp := &Replayer{Handler: &wabaapi.WebhookHandler{Handle: func(msg *wabaapi.InboundMessage) error {
+		replayed = append(replayed, msg)
+		return nil
+	}}}
+	results, err := rp.Replay(context.Background(), &rec)
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	assert.Equal(t, http.StatusOK, results[0].Status)
+	assert.Equal(t, http.StatusBadRequest, results[1].Status)
+
+	payload := replayed[0].Payload.(wabaapi.InboundMessagePayload)
+	assert.Equal(t, wabaapi.InboundText("hi"), payload.Payload)
+	assert.Equal(t, payload.Source, payload.Sender.Phone)
+	assert.Len(t, payload.Source, len("34600000001"))
+	assert.Equal(t, "34", payload.Sender.CountryCode)
+	assert.Equal(t, payload.Sender.Phone, payload.Sender.CountryCode+payload.Sender.DialCode)
+}
+
+func TestReplayPacing(t *testing.T) {
+	var rec bytes.Buffer
+	recorder := &Recorder{W: &rec}
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		require.NoError(t, recorder.Write(Record{ReceivedAt: start.Add(time.Duration(i) * time.Second)}, []byte(textCallback)))
+	}
+
+	rp := &Replayer{Handler: http.NotFoundHandler(), Speed: 20}
+	began := time.Now()
+	results, err := rp.Replay(context.Background(), &rec)
+	require.NoError(t, err)
+	assert.Len(t, results, 3)
+	assert.GreaterOrEqual(t, int64(time.Since(began)), int64(100*time.Millisecond))
+}